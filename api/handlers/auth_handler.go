@@ -1,74 +1,400 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
+	"time"
+	"todo-backend/auth"
+	"todo-backend/config"
+	"todo-backend/mail"
+	"todo-backend/middleware"
+	"todo-backend/models"
+	"todo-backend/repositories"
 
-	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-var clerkClient clerk.Client
+// mailSendTimeout bounds how long sending an account email may take
+const mailSendTimeout = 30 * time.Second
 
-func init() {
-	err := godotenv.Load()
+// Password reset emails are sent by a few workers from a bounded queue, and each email address
+// and client IP may only ask for a few of them an hour
+const (
+	passwordResetWorkers  = 2
+	passwordResetQueue    = 64
+	passwordResetWindow   = time.Hour
+	passwordResetsByEmail = 3
+	passwordResetsByIP    = 10
+)
+
+var errNotAuthenticated = errors.New("request is not authenticated")
+
+// currentUser returns the user middleware.AuthMiddleware authenticated the request as. A Clerk
+// user we have no row for yet has a nil ID; see noteUserID.
+func currentUser(c *gin.Context) (*models.User, error) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return nil, errNotAuthenticated
+	}
+	return user, nil
+}
+
+// AuthHandler signs users in with an email address and password, for accounts that don't go
+// through Clerk. A session is a short-lived access token plus a refresh token that is replaced
+// on every use.
+type AuthHandler struct {
+	userRepo      *repositories.UserRepository
+	refreshRepo   *repositories.RefreshTokenRepository
+	resetRepo     *repositories.PasswordResetRepository
+	mailer        mail.Sender // nil when password resets are disabled
+	resets        chan string
+	resetsByEmail *rateLimiter
+	resetsByIP    *rateLimiter
+}
+
+func NewAuthHandler(db *gorm.DB, mailer mail.Sender) *AuthHandler {
+	h := &AuthHandler{
+		userRepo:      repositories.NewUserRepository(db),
+		refreshRepo:   repositories.NewRefreshTokenRepository(db),
+		resetRepo:     repositories.NewPasswordResetRepository(db),
+		mailer:        mailer,
+		resets:        make(chan string, passwordResetQueue),
+		resetsByEmail: newRateLimiter(passwordResetsByEmail, passwordResetWindow),
+		resetsByIP:    newRateLimiter(passwordResetsByIP, passwordResetWindow),
+	}
+	if mailer != nil {
+		for i := 0; i < passwordResetWorkers; i++ {
+			go func() {
+				for email := range h.resets {
+					h.sendPasswordReset(email)
+				}
+			}()
+		}
+	}
+	return h
+}
+
+// Register creates an account and signs it in
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	existing, err := h.userRepo.GetByEmail(email)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Error checking existing user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user existence"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Println("No .env file found, using system environment variables")
+		log.Printf("Password hashing error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	user := models.User{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     email,
+		Password:  string(hash),
+	}
+	if err := h.userRepo.Create(&user); err != nil {
+		log.Printf("Register error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
 	}
 
-	secret := os.Getenv("CLERK_SECRET_KEY")
-	if secret == "" {
-		log.Fatal("CLERK_SECRET_KEY is not set in the environment")
+	h.startSession(c, http.StatusCreated, &user)
+}
+
+// Login signs in with an email address and password
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByEmail(strings.TrimSpace(req.Email))
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Login lookup error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign in"})
+		return
+	}
+	// Compare against a dummy hash for unknown emails and Clerk-only accounts, so response
+	// times don't tell them apart from a wrong password
+	hash := dummyPasswordHash()
+	if user != nil && user.Password != "" {
+		hash = user.Password
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil || user == nil || user.Password == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	h.startSession(c, http.StatusOK, user)
+}
+
+// Refresh trades a refresh token for a new access token and the next refresh token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Refresh token generation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+		return
+	}
+	next := models.RefreshToken{TokenHash: hashShareToken(token), ExpiresAt: time.Now().Add(config.RefreshTokenTTL())}
+	err = h.refreshRepo.Rotate(hashShareToken(req.RefreshToken), &next)
+	if err == repositories.ErrRefreshTokenReused {
+		log.Printf("Refresh token reuse detected; session revoked")
+	}
+	if err == repositories.ErrRefreshTokenInvalid || err == repositories.ErrRefreshTokenReused {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Refresh token rotation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+		return
 	}
 
-	clerkClient, err = clerk.NewClient(secret)
+	user, err := h.userRepo.GetByID(next.UserID)
+	if err == gorm.ErrRecordNotFound {
+		if err := h.refreshRepo.RevokeSession(next.TokenHash); err != nil {
+			log.Printf("Session revoke error: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to initialize Clerk client: %v", err)
+		log.Printf("Refresh user lookup error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+		return
 	}
+
+	h.respondWithTokens(c, http.StatusOK, user, token)
 }
 
-func extractUserFromHeader(c *gin.Context) (*clerk.User, error) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
-		return nil, errMissingToken()
+// Logout revokes the session the given refresh token belongs to. Access tokens already
+// handed out stay valid until they expire.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
-		return nil, errInvalidToken()
+	if err := h.refreshRepo.RevokeSession(hashShareToken(req.Token)); err != nil {
+		log.Printf("Logout error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not log out"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
 
-	token := parts[1]
-	sessionClaims, err := clerkClient.VerifyToken(token)
+// ForgotPassword emails a password reset link. It answers the same way, and just as quickly,
+// whether or not the email belongs to an account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	if h.mailer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Password reset is not available"})
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	now := time.Now()
+	if !h.resetsByIP.Allow(c.ClientIP(), now) || !h.resetsByEmail.Allow(email, now) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
+		return
+	}
+	select {
+	case h.resets <- email:
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many password reset requests, try again later"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If that email is registered, a reset link is on its way"})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and signs the user out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Password hashing error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	_, err = h.resetRepo.ResetPassword(hashShareToken(req.Token), string(hash))
+	if err == repositories.ErrResetTokenInvalid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
 	if err != nil {
-		log.Printf("Token verification failed: %v", err)
-		return nil, err
+		log.Printf("Password reset error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
 
-	user, err := clerkClient.Users().Read(sessionClaims.Subject)
+// sendPasswordReset issues a reset token for the account with this email, if there is one with
+// a password (Clerk accounts reset theirs with Clerk), and emails the link
+func (h *AuthHandler) sendPasswordReset(email string) {
+	user, err := h.userRepo.GetByEmail(email)
 	if err != nil {
-		log.Printf("Failed to fetch user: %v", err)
-		return nil, err
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Password reset lookup error: %v", err)
+		}
+		return
+	}
+	if user.Password == "" {
+		return
 	}
 
-	return user, nil
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Password reset token generation error: %v", err)
+		return
+	}
+	ttl := config.PasswordResetTTL()
+	reset := models.PasswordResetToken{UserID: user.ID, TokenHash: hashShareToken(token), ExpiresAt: time.Now().Add(ttl)}
+	if err := h.resetRepo.Create(&reset); err != nil {
+		log.Printf("Password reset token save error: %v", err)
+		return
+	}
+
+	link := config.AppURL() + "/reset-password?token=" + token
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your account. To choose a new one, open this link within %d minutes:\n\n%s\n\nIf it wasn't you, ignore this email; your password stays the same.\n",
+			int(ttl.Minutes()), link),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Password reset email error: %v", err)
+	}
+}
+
+// startSession opens a new session for the user and responds with its tokens
+func (h *AuthHandler) startSession(c *gin.Context, status int, user *models.User) {
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Refresh token generation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign in"})
+		return
+	}
+	refresh := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashShareToken(token),
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+	}
+	if err := h.refreshRepo.Create(&refresh); err != nil {
+		log.Printf("Refresh token save error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign in"})
+		return
+	}
+
+	h.respondWithTokens(c, status, user, token)
+}
+
+// respondWithTokens issues an access token to go with the refresh token
+func (h *AuthHandler) respondWithTokens(c *gin.Context, status int, user *models.User, refreshToken string) {
+	accessToken, expiresAt, err := auth.IssueAccessToken(user.ID, config.JWTSecret(), config.AccessTokenTTL())
+	if err != nil {
+		log.Printf("Access token error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not sign in"})
+		return
+	}
+	c.JSON(status, models.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
+		UserID:       user.ID,
+	})
 }
 
-func errMissingToken() error {
-	return &gin.Error{
-		Err:  http.ErrNoCookie,
-		Type: gin.ErrorTypePrivate,
+// dummyPasswordHash is a bcrypt hash that matches no password, computed once on first use
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Password hashing error: %v", err)
 	}
+	return string(hash)
+})
+
+// rateLimiter allows each key limit events per window, counted from the key's first event in it
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, windows: make(map[string]rateWindow)}
 }
 
-func errInvalidToken() error {
-	return &gin.Error{
-		Err:  http.ErrNotSupported,
-		Type: gin.ErrorTypePrivate,
+// Allow records an event for key at now and reports whether it is within the limit
+func (l *rateLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		// Forget finished windows now and then, so the map only holds recent keys
+		if len(l.windows) >= 10000 {
+			for k, old := range l.windows {
+				if now.Sub(old.start) >= l.window {
+					delete(l.windows, k)
+				}
+			}
+		}
+		w = rateWindow{start: now}
+	}
+	if w.count >= l.limit {
+		return false
 	}
+	w.count++
+	l.windows[key] = w
+	return true
 }
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"todo-backend/config"
+	"todo-backend/ical"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const calendarProdID = "-//todo-backend//Reminders//EN"
+
+type CalendarHandler struct {
+	feedRepo *repositories.CalendarFeedRepository
+	userRepo *repositories.UserRepository
+}
+
+func NewCalendarHandler(db *gorm.DB) *CalendarHandler {
+	return &CalendarHandler{
+		feedRepo: repositories.NewCalendarFeedRepository(db),
+		userRepo: repositories.NewUserRepository(db),
+	}
+}
+
+// GetFeed tells the caller whether they have a calendar subscription. The URL itself is only
+// shown when the token is generated.
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	feed, err := h.feedRepo.GetByUser(noteUserID(user))
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No calendar feed yet"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch calendar feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toCalendarFeedResponse(feed))
+}
+
+// RotateFeed issues a new secret feed URL for the caller, replacing (and disabling) any previous one
+func (h *CalendarHandler) RotateFeed(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Calendar token generation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create calendar feed"})
+		return
+	}
+
+	feed, err := h.feedRepo.SetToken(noteUserID(user), hashShareToken(token))
+	if err != nil {
+		log.Printf("Failed to save calendar feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create calendar feed"})
+		return
+	}
+
+	// The raw token is only ever returned here; we keep just its hash
+	response := toCalendarFeedResponse(feed)
+	response.URL = requestBaseURL(c) + "/calendar/" + token + ".ics"
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteFeed turns the caller's calendar subscription off
+func (h *CalendarHandler) DeleteFeed(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.feedRepo.Delete(noteUserID(user)); err != nil {
+		log.Printf("Failed to delete calendar feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed deleted"})
+}
+
+// GetCalendar renders the feed owner's reminders as an iCalendar document for calendar apps to
+// subscribe to: GET /calendar/<token>.ics. The token is the only credential. Times are shown in
+// the owner's preferred time zone unless a reminder has its own.
+func (h *CalendarHandler) GetCalendar(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+	if !ok || token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	feed, err := h.feedRepo.GetByTokenHash(hashShareToken(token))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	reminders, err := h.feedRepo.GetReminders(feed.UserID)
+	if err != nil {
+		log.Printf("Failed to fetch calendar reminders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build calendar"})
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(reminders))
+	for _, r := range reminders {
+		ids = append(ids, r.ID)
+	}
+	overrides, err := h.feedRepo.GetOverrides(ids)
+	if err != nil {
+		log.Printf("Failed to fetch calendar reminder overrides: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build calendar"})
+		return
+	}
+
+	prefs := userPreferences(h.userRepo, feed.UserID)
+	loc := repositories.PreferredLocation(prefs)
+	cal := ical.Calendar{ProdID: calendarProdID, Name: "Reminders"}
+	if loc != time.UTC {
+		cal.Timezone = loc.String()
+	}
+	for i := range reminders {
+		cal.Events = append(cal.Events, reminderEvents(&reminders[i], overrides[reminders[i].ID], loc)...)
+	}
+
+	var buf bytes.Buffer
+	if err := cal.Write(&buf); err != nil {
+		log.Printf("Calendar render error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build calendar"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", `inline; filename="reminders.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// reminderEvents maps a reminder to a VEVENT linking back to its note. A recurring reminder
+// becomes one repeating event, with skipped occurrences excluded and moved ones replaced by
+// events carrying their RECURRENCE-ID. A one-off reminder without a time zone is shown in
+// defaultLoc; a recurring one stays in UTC, where its rule is expanded.
+func reminderEvents(reminder *models.Reminder, overrides []models.ReminderOverride, defaultLoc *time.Location) []ical.Event {
+	loc, err := repositories.ReminderLocation(reminder.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if reminder.Timezone == "" && reminder.RRule == "" {
+		loc = defaultLoc
+	}
+	summary := reminder.Note.Title
+	if summary == "" {
+		summary = "Reminder"
+	}
+	event := ical.Event{
+		UID:         "reminder-" + reminder.ID.String(),
+		Stamp:       reminder.UpdatedAt,
+		Start:       reminder.Time,
+		Location:    loc,
+		Summary:     summary,
+		Description: reminder.Note.Description,
+		URL:         config.AppURL() + "/notes/" + reminder.NoteID.String(),
+		Alarm:       true,
+	}
+	if reminder.RRule == "" {
+		return []ical.Event{event}
+	}
+
+	// Calendars always count DTSTART as the first occurrence, so start at the rule's actual first one
+	first, err := repositories.ReminderOccurrences(reminder, nil, reminder.Time, 1)
+	if err != nil || len(first) == 0 {
+		return nil
+	}
+	event.Start = first[0]
+	event.RRule = reminder.RRule
+
+	events := []ical.Event{event}
+	for _, o := range overrides {
+		if o.Time == nil {
+			events[0].ExDates = append(events[0].ExDates, o.OriginalTime)
+			continue
+		}
+		moved := event
+		moved.Start = *o.Time
+		moved.RRule = ""
+		moved.RecurrenceID = &o.OriginalTime
+		events = append(events, moved)
+	}
+	return events
+}
+
+// requestBaseURL is the scheme and host the request reached us on
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func toCalendarFeedResponse(feed *models.CalendarFeed) models.CalendarFeedResponse {
+	return models.CalendarFeedResponse{
+		CreatedAt: feed.CreatedAt,
+		UpdatedAt: feed.UpdatedAt,
+	}
+}
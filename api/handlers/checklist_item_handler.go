@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChecklistItemHandler struct {
+	noteRepo     *repositories.NoteRepository
+	collabRepo   *repositories.CollaboratorRepository
+	revisionRepo *repositories.RevisionRepository
+	broker       realtime.Broker
+}
+
+func NewChecklistItemHandler(db *gorm.DB, broker realtime.Broker) *ChecklistItemHandler {
+	return &ChecklistItemHandler{
+		noteRepo:     repositories.NewNoteRepository(db),
+		collabRepo:   repositories.NewCollaboratorRepository(db),
+		revisionRepo: repositories.NewRevisionRepository(db),
+		broker:       broker,
+	}
+}
+
+func (h *ChecklistItemHandler) CreateItem(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	var req models.CreateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := models.ChecklistItem{
+		ID:        uuid.New(),
+		NoteID:    note.ID,
+		ParentID:  req.ParentID,
+		Text:      req.Text,
+		IsChecked: req.IsChecked,
+	}
+	if item.ParentID != nil && !h.validateParent(c, &item, *item.ParentID) {
+		return
+	}
+	if err := h.noteRepo.CreateChecklistItem(&item); err != nil {
+		log.Printf("Checklist create error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create checklist item"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusCreated, h.itemResponse(&item))
+}
+
+func (h *ChecklistItemHandler) UpdateItem(c *gin.Context) {
+	user, note, item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldParentID := item.ParentID
+	if req.ParentID != nil {
+		item.ParentID = nil
+		if *req.ParentID != "" {
+			parentID, err := uuid.Parse(*req.ParentID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+				return
+			}
+			if !h.validateParent(c, item, parentID) {
+				return
+			}
+			item.ParentID = &parentID
+		}
+	}
+	if req.Text != nil {
+		item.Text = *req.Text
+	}
+	if err := h.noteRepo.UpdateChecklistItem(item); err != nil {
+		log.Printf("Checklist update error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update checklist item"})
+		return
+	}
+
+	// Moving an item between sub-task lists can complete or reopen both the old and new parent
+	if !sameParent(oldParentID, item.ParentID) {
+		for _, parentID := range []*uuid.UUID{oldParentID, item.ParentID} {
+			if err := h.noteRepo.RollUpChecklist(parentID); err != nil {
+				log.Printf("Checklist roll-up error: %v", err)
+			}
+		}
+	}
+
+	if req.IsChecked != nil && *req.IsChecked != item.IsChecked {
+		if err := h.noteRepo.SetChecklistItemChecked(item, *req.IsChecked); err != nil {
+			log.Printf("Checklist update error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update checklist item"})
+			return
+		}
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, h.itemResponse(item))
+}
+
+func (h *ChecklistItemHandler) ToggleItem(c *gin.Context) {
+	user, note, item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteRepo.ToggleChecklistItem(item); err != nil {
+		log.Printf("Checklist toggle error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle checklist item"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, h.itemResponse(item))
+}
+
+func (h *ChecklistItemHandler) DeleteItem(c *gin.Context) {
+	user, note, item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteRepo.DeleteChecklistItem(item.ID); err != nil {
+		log.Printf("Checklist delete error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete checklist item"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, gin.H{"message": "Checklist item deleted"})
+}
+
+// ReorderItems rewrites the order of a note's checklist from the full list of item IDs
+func (h *ChecklistItemHandler) ReorderItems(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	var req models.ReorderChecklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.noteRepo.ReorderChecklistItems(note.ID, req.ItemIDs); err != nil {
+		if err == repositories.ErrInvalidOrder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Checklist reorder error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder checklist"})
+		return
+	}
+
+	items, err := h.noteRepo.GetChecklistItemsByNoteID(note.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch checklist items"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, toChecklistTree(items))
+}
+
+// MoveItem places a checklist item between two neighbours of the same note
+func (h *ChecklistItemHandler) MoveItem(c *gin.Context) {
+	user, note, item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+
+	var req models.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.noteRepo.MoveChecklistItem(item, req.AfterID, req.BeforeID); err != nil {
+		if err == repositories.ErrInvalidMove {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Checklist move error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move checklist item"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, h.itemResponse(item))
+}
+
+// loadItem authorizes the caller as an editor of the note and loads the item from the path
+func (h *ChecklistItemHandler) loadItem(c *gin.Context) (*models.User, *models.Note, *models.ChecklistItem, bool) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checklist item ID"})
+		return nil, nil, nil, false
+	}
+
+	item, err := h.noteRepo.GetChecklistItem(note.ID, itemID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checklist item not found"})
+		return nil, nil, nil, false
+	}
+
+	return user, note, item, true
+}
+
+// validateParent checks parentID can hold item as a sub-task, writing an error response if not
+func (h *ChecklistItemHandler) validateParent(c *gin.Context, item *models.ChecklistItem, parentID uuid.UUID) bool {
+	err := h.noteRepo.ValidateChecklistParent(item.NoteID, item.ID, parentID)
+	if err == repositories.ErrInvalidParent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		log.Printf("Checklist parent check error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check parent item"})
+		return false
+	}
+	return true
+}
+
+// itemResponse renders an item with its current sub-tasks
+func (h *ChecklistItemHandler) itemResponse(item *models.ChecklistItem) models.ChecklistItemResponse {
+	items, err := h.noteRepo.GetChecklistItemsByNoteID(item.NoteID)
+	if err == nil {
+		if node := findChecklistNode(toChecklistTree(items), item.ID); node != nil {
+			return *node
+		}
+	}
+	return toChecklistItemResponse(item)
+}
+
+// noteChanged bumps the note's version, records a revision and notifies everyone on the note
+func (h *ChecklistItemHandler) noteChanged(note *models.Note, user *models.User) {
+	if err := h.noteRepo.Touch(note, noteUserID(user)); err != nil {
+		log.Printf("Note touch error: %v", err)
+	}
+	if _, err := h.revisionRepo.Record(note.ID, noteUserID(user)); err != nil {
+		log.Printf("Revision record error: %v", err)
+	}
+	h.broker.Publish(realtime.Event{
+		Type:       realtime.EventNoteUpdated,
+		NoteID:     note.ID,
+		Actor:      noteUserID(user),
+		Recipients: noteRecipients(h.collabRepo, note),
+	})
+}
+
+func toChecklistItemResponse(item *models.ChecklistItem) models.ChecklistItemResponse {
+	return models.ChecklistItemResponse{
+		ID:        item.ID,
+		ParentID:  item.ParentID,
+		Text:      item.Text,
+		IsChecked: item.IsChecked,
+		Rank:      item.Rank,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+	}
+}
+
+// toChecklistTree nests rank-ordered items under their parents. Items whose parent is
+// missing (e.g. deleted) are shown at the top level.
+func toChecklistTree(items []models.ChecklistItem) []models.ChecklistItemResponse {
+	present := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		present[item.ID] = true
+	}
+	children := make(map[uuid.UUID][]*models.ChecklistItem)
+	var roots []*models.ChecklistItem
+	for i := range items {
+		item := &items[i]
+		if item.ParentID != nil && present[*item.ParentID] {
+			children[*item.ParentID] = append(children[*item.ParentID], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	visited := make(map[uuid.UUID]bool, len(items))
+	var build func(item *models.ChecklistItem) models.ChecklistItemResponse
+	build = func(item *models.ChecklistItem) models.ChecklistItemResponse {
+		visited[item.ID] = true
+		response := toChecklistItemResponse(item)
+		for _, child := range children[item.ID] {
+			if !visited[child.ID] {
+				response.Children = append(response.Children, build(child))
+			}
+		}
+		return response
+	}
+
+	tree := []models.ChecklistItemResponse{}
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree
+}
+
+func findChecklistNode(tree []models.ChecklistItemResponse, id uuid.UUID) *models.ChecklistItemResponse {
+	for i := range tree {
+		if tree[i].ID == id {
+			return &tree[i]
+		}
+		if node := findChecklistNode(tree[i].Children, id); node != nil {
+			return node
+		}
+	}
+	return nil
+}
+
+// checklistCompletion returns the percentage of leaf items (those without sub-tasks) that are
+// checked, or nil for an empty checklist
+func checklistCompletion(items []models.ChecklistItem) *int {
+	if len(items) == 0 {
+		return nil
+	}
+	hasChildren := make(map[uuid.UUID]bool)
+	for _, item := range items {
+		if item.ParentID != nil {
+			hasChildren[*item.ParentID] = true
+		}
+	}
+	var leaves, checked int
+	for _, item := range items {
+		if hasChildren[item.ID] {
+			continue
+		}
+		leaves++
+		if item.IsChecked {
+			checked++
+		}
+	}
+	percent := checked * 100 / leaves
+	return &percent
+}
+
+func sameParent(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
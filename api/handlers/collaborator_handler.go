@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CollaboratorHandler struct {
+	noteRepo   *repositories.NoteRepository
+	userRepo   *repositories.UserRepository
+	collabRepo *repositories.CollaboratorRepository
+}
+
+func NewCollaboratorHandler(db *gorm.DB) *CollaboratorHandler {
+	return &CollaboratorHandler{
+		noteRepo:   repositories.NewNoteRepository(db),
+		userRepo:   repositories.NewUserRepository(db),
+		collabRepo: repositories.NewCollaboratorRepository(db),
+	}
+}
+
+// InviteCollaborator shares a note with the user who verified the given email. The response is
+// the same whether or not there is such a user, so invitations can't be used to find accounts.
+func (h *CollaboratorHandler) InviteCollaborator(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.InviteCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invited := gin.H{"message": "If an account has verified this email, it now has access to the note"}
+	invitee, err := h.userRepo.GetByVerifiedEmail(req.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusAccepted, invited)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up invitee: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add collaborator"})
+		return
+	}
+
+	inviteeID := noteUserID(invitee)
+	if inviteeID == note.CreatedBy {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner already has access to this note"})
+		return
+	}
+
+	if existing, _ := h.collabRepo.GetByNoteAndUser(note.ID, inviteeID); existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a collaborator"})
+		return
+	}
+
+	collaborator := models.NoteCollaborator{
+		ID:        uuid.New(),
+		NoteID:    note.ID,
+		UserID:    inviteeID,
+		Email:     invitee.Email,
+		Role:      req.Role,
+		InvitedBy: noteUserID(user),
+	}
+	if err := h.collabRepo.Create(&collaborator); err != nil {
+		log.Printf("Failed to add collaborator: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add collaborator"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, invited)
+}
+
+func (h *CollaboratorHandler) ListCollaborators(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	collaborators, err := h.collabRepo.GetAllByNote(note.ID)
+	if err != nil {
+		log.Printf("Failed to fetch collaborators: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch collaborators"})
+		return
+	}
+
+	response := []models.CollaboratorResponse{}
+	for i := range collaborators {
+		response = append(response, toCollaboratorResponse(&collaborators[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *CollaboratorHandler) UpdateCollaborator(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCollaboratorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collaborator, err := h.collabRepo.GetByNoteAndUser(note.ID, c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	collaborator.Role = req.Role
+	if err := h.collabRepo.Update(collaborator); err != nil {
+		log.Printf("Failed to update collaborator: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collaborator"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toCollaboratorResponse(collaborator))
+}
+
+// RevokeCollaborator removes a collaborator. The owner can revoke anyone; collaborators can only remove themselves.
+func (h *CollaboratorHandler) RevokeCollaborator(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	targetID := c.Param("userId")
+	if note.CreatedBy != noteUserID(user) && targetID != noteUserID(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can revoke other collaborators"})
+		return
+	}
+
+	collaborator, err := h.collabRepo.GetByNoteAndUser(note.ID, targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+
+	if err := h.collabRepo.Delete(collaborator); err != nil {
+		log.Printf("Failed to revoke collaborator: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+// noteUserID returns the identifier notes use for a user in CreatedBy and collaborator entries
+func noteUserID(user *models.User) string {
+	if user.ClerkID != "" {
+		return user.ClerkID
+	}
+	return user.ID.String()
+}
+
+func toCollaboratorResponse(collaborator *models.NoteCollaborator) models.CollaboratorResponse {
+	return models.CollaboratorResponse{
+		UserID:    collaborator.UserID,
+		Email:     collaborator.Email,
+		Role:      collaborator.Role,
+		InvitedBy: collaborator.InvitedBy,
+		CreatedAt: collaborator.CreatedAt,
+		UpdatedAt: collaborator.UpdatedAt,
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LabelHandler struct {
+	repo *repositories.LabelRepository
+}
+
+func NewLabelHandler(db *gorm.DB) *LabelHandler {
+	return &LabelHandler{
+		repo: repositories.NewLabelRepository(db),
+	}
+}
+
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
+		return
+	}
+
+	if existing, _ := h.repo.GetByName(noteUserID(user), name); existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Label already exists"})
+		return
+	}
+
+	label := models.Label{
+		ID:        uuid.New(),
+		Name:      name,
+		Color:     req.Color,
+		CreatedBy: noteUserID(user),
+	}
+	if err := h.repo.Create(&label); err != nil {
+		log.Printf("Failed to create label: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create label"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toLabelResponse(&label))
+}
+
+func (h *LabelHandler) ListLabels(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	labels, err := h.repo.GetAllByUser(noteUserID(user))
+	if err != nil {
+		log.Printf("Failed to fetch labels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch labels"})
+		return
+	}
+
+	response := []models.LabelResponse{}
+	for i := range labels {
+		response = append(response, toLabelResponse(&labels[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *LabelHandler) GetLabel(c *gin.Context) {
+	label, ok := h.loadOwnedLabel(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toLabelResponse(label))
+}
+
+func (h *LabelHandler) UpdateLabel(c *gin.Context) {
+	label, ok := h.loadOwnedLabel(c)
+	if !ok {
+		return
+	}
+
+	var req models.LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
+		return
+	}
+
+	if existing, _ := h.repo.GetByName(label.CreatedBy, name); existing != nil && existing.ID != label.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Label already exists"})
+		return
+	}
+
+	label.Name = name
+	label.Color = req.Color
+	if err := h.repo.Update(label); err != nil {
+		log.Printf("Failed to update label: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toLabelResponse(label))
+}
+
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	label, ok := h.loadOwnedLabel(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Delete(label.ID); err != nil {
+		log.Printf("Failed to delete label: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label deleted successfully"})
+}
+
+// loadOwnedLabel authenticates the caller and loads the label in the :id param, writing an error response if it is missing or not theirs
+func (h *LabelHandler) loadOwnedLabel(c *gin.Context) (*models.Label, bool) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	labelID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return nil, false
+	}
+
+	label, err := h.repo.GetByID(labelID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return nil, false
+	}
+
+	if label.CreatedBy != noteUserID(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to access this label"})
+		return nil, false
+	}
+
+	return label, true
+}
+
+func toLabelResponse(label *models.Label) models.LabelResponse {
+	return models.LabelResponse{
+		ID:        label.ID,
+		Name:      label.Name,
+		Color:     label.Color,
+		CreatedAt: label.CreatedAt,
+		UpdatedAt: label.UpdatedAt,
+	}
+}
@@ -1,10 +1,22 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
+	"todo-backend/jsonpatch"
 	"todo-backend/models"
+	"todo-backend/quickadd"
+	"todo-backend/realtime"
 	"todo-backend/repositories"
 
 	"github.com/gin-gonic/gin"
@@ -13,19 +25,31 @@ import (
 )
 
 type NoteHandler struct {
-	repo     *repositories.NoteRepository
-	userRepo *repositories.UserRepository
+	repo         *repositories.NoteRepository
+	userRepo     *repositories.UserRepository
+	labelRepo    *repositories.LabelRepository
+	revisionRepo *repositories.RevisionRepository
+	collabRepo   *repositories.CollaboratorRepository
+	reminderRepo *repositories.ReminderRepository
+	ticketRepo   *repositories.StreamTicketRepository
+	broker       realtime.Broker
 }
 
-func NewNoteHandler(db *gorm.DB) *NoteHandler {
+func NewNoteHandler(db *gorm.DB, broker realtime.Broker) *NoteHandler {
 	return &NoteHandler{
-		repo:     repositories.NewNoteRepository(db),
-		userRepo: repositories.NewUserRepository(db),
+		repo:         repositories.NewNoteRepository(db),
+		userRepo:     repositories.NewUserRepository(db),
+		labelRepo:    repositories.NewLabelRepository(db),
+		revisionRepo: repositories.NewRevisionRepository(db),
+		collabRepo:   repositories.NewCollaboratorRepository(db),
+		reminderRepo: repositories.NewReminderRepository(db),
+		ticketRepo:   repositories.NewStreamTicketRepository(db),
+		broker:       broker,
 	}
 }
 
 func (h *NoteHandler) CreateNote(c *gin.Context) {
-	user, err := extractUserFromHeader(c)
+	user, err := currentUser(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
@@ -37,7 +61,95 @@ func (h *NoteHandler) CreateNote(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	reminders, err := remindersFromRequest(req.Reminders, user.Preferences.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.createNote(user, &req, reminders)
+	if err != nil {
+		log.Printf("Failed to create note: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create note"})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// QuickAddNote creates a note from a line of free text such as "Buy milk tomorrow 6pm #groceries !pin",
+// resolving dates in the given time zone (or the user's) and with the user's default reminder time
+// and week start, and returns what it understood alongside the note.
+// With dry_run set it only parses, so the client can confirm first.
+func (h *NoteHandler) QuickAddNote(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.QuickAddRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs := user.Preferences
+	if req.Timezone == "" {
+		req.Timezone = prefs.Timezone
+	}
+	loc, err := repositories.ReminderLocation(req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone " + req.Timezone})
+		return
+	}
+
+	opts := quickadd.Options{WeekStart: repositories.PreferredWeekStart(prefs)}
+	opts.DefaultHour, opts.DefaultMinute = repositories.PreferredReminderClock(prefs)
+	result := quickadd.Parse(req.Text, time.Now().In(loc), opts)
+	if result.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing left for the note title"})
+		return
+	}
 
+	noteReq := models.CreateNoteRequest{
+		Title:      result.Title,
+		IsPinned:   result.Pinned,
+		IsArchived: result.Archived,
+		Labels:     result.Labels,
+	}
+	parsed := models.QuickAddParsed{
+		Title:      result.Title,
+		Labels:     result.Labels,
+		IsPinned:   result.Pinned,
+		IsArchived: result.Archived,
+		Phrases:    result.Phrases,
+	}
+	if result.Reminder != nil {
+		reminder := models.ReminderRequest{Time: *result.Reminder, RRule: result.RRule, Timezone: req.Timezone}
+		noteReq.Reminders = []models.ReminderRequest{reminder}
+		parsed.Reminder = &reminder
+	}
+	if req.DryRun {
+		c.JSON(http.StatusOK, models.QuickAddResponse{Parsed: parsed})
+		return
+	}
+
+	reminders, err := remindersFromRequest(noteReq.Reminders, req.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response, err := h.createNote(user, &noteReq, reminders)
+	if err != nil {
+		log.Printf("Failed to create note: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create note"})
+		return
+	}
+	c.JSON(http.StatusCreated, models.QuickAddResponse{Parsed: parsed, Note: &response})
+}
+
+// createNote saves a new note with its checklist items, reminders and labels, records its first
+// revision and announces it
+func (h *NoteHandler) createNote(user *models.User, req *models.CreateNoteRequest, reminders []models.Reminder) (models.NoteResponse, error) {
 	noteID := uuid.New()
 	note := models.Note{
 		ID:          noteID,
@@ -46,292 +158,1017 @@ func (h *NoteHandler) CreateNote(c *gin.Context) {
 		IsPinned:    req.IsPinned,
 		IsArchived:  req.IsArchived,
 		IsChecklist: req.IsChecklist,
-		CreatedBy:   user.ID,
-		UpdatedBy:   user.ID,
+		Version:     1,
+		CreatedBy:   noteUserID(user),
+		UpdatedBy:   noteUserID(user),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
 	// Save note
 	if err := h.repo.Create(&note); err != nil {
-		log.Printf("Failed to create note: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create note"})
-		return
+		return models.NoteResponse{}, err
 	}
 
-	// Save checklist items
-	for _, item := range req.ChecklistItems {
-		itemID := uuid.New()
-		newItem := models.ChecklistItem{
-			ID:        itemID,
-			NoteID:    noteID,
-			Text:      item.Text,
-			IsChecked: item.IsChecked,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		if err := h.repo.CreateChecklistItem(&newItem); err != nil {
-			log.Printf("Checklist create error: %v", err)
-		}
+	// Save checklist items; their IDs only link sub-tasks to parents within the request
+	if err := h.repo.ReplaceChecklistItems(noteID, req.ChecklistItems); err != nil {
+		log.Printf("Checklist create error: %v", err)
 	}
 
 	// Save reminders
-	for _, r := range req.Reminders {
-		reminder := models.Reminder{
-			ID:     uuid.New(),
-			NoteID: noteID,
-			Time:   r.Time, // this now works because r is time.Time
-		}
+	for _, reminder := range reminders {
+		reminder.ID = uuid.New()
+		reminder.NoteID = noteID
 		if err := h.repo.CreateReminder(&reminder); err != nil {
 			log.Printf("Reminder create error: %v", err)
 		}
 	}
 
-	// Fetch the checklist and reminders again to include in response
-	checklistModel, _ := h.repo.GetChecklistItemsByNoteID(noteID)
-	var checklist []models.ChecklistItemResponse
-	for _, item := range checklistModel {
-		checklist = append(checklist, models.ChecklistItemResponse{
-			ID:        item.ID,
-			Text:      item.Text,
-			IsChecked: item.IsChecked,
-			CreatedAt: item.CreatedAt,
-			UpdatedAt: item.UpdatedAt,
-		})
+	// Attach labels
+	if err := h.assignLabels(noteUserID(user), noteID, req.Labels); err != nil {
+		log.Printf("Label assign error: %v", err)
 	}
 
-	remindersModel, _ := h.repo.GetRemindersByNoteID(noteID)
-
-	var reminders []models.ReminderResponse
-	for _, r := range remindersModel {
-		reminders = append(reminders, models.ReminderResponse{
-			Time: r.Time,
-		})
+	// Record the initial revision
+	if _, err := h.revisionRepo.Record(noteID, noteUserID(user)); err != nil {
+		log.Printf("Revision record error: %v", err)
 	}
 
 	// Build response
-	response := models.NoteResponse{
-		ID:             note.ID,
-		Title:          note.Title,
-		Description:    note.Description,
-		FirstName:      user.FirstName,
-		CreatedAt:      note.CreatedAt,
-		UpdatedAt:      note.UpdatedAt,
-		CreatedBy:      note.CreatedBy,
-		UpdatedBy:      note.UpdatedBy,
-		ChecklistItems: checklist,
-		Reminders:      reminders,
-	}
-	c.JSON(http.StatusCreated, response)
+	response := h.buildNoteResponse(&note, &user.FirstName)
+	h.publish(realtime.EventNoteCreated, &note, noteUserID(user), response)
+	return response, nil
 }
 
 func (h *NoteHandler) GetAllNotes(c *gin.Context) {
-	user, err := extractUserFromHeader(c)
+	user, err := currentUser(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	notes, err := h.repo.GetAllByUser(user.ID)
+	opts, err := parseNoteListOptions(c, user.Preferences.DefaultNoteSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notes, total, next, err := h.repo.List(noteUserID(user), opts)
+	if err == repositories.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to fetch notes: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch notes"})
 		return
 	}
 
-	var response []models.NoteResponse
-	for _, n := range notes {
-
-		items, _ := h.repo.GetChecklistItemsByNoteID(n.ID)
-		var checklist []models.ChecklistItemResponse
-		for _, item := range items {
-			checklist = append(checklist, models.ChecklistItemResponse{
-				ID:        item.ID,
-				Text:      item.Text,
-				IsChecked: item.IsChecked,
-				CreatedAt: item.CreatedAt,
-				UpdatedAt: item.UpdatedAt,
-			})
-		}
-
-		remindersModel, _ := h.repo.GetRemindersByNoteID(n.ID)
-		var reminders []models.ReminderResponse
-		for _, r := range remindersModel {
-			reminders = append(reminders, models.ReminderResponse{
-				Time: r.Time,
-			})
-		}
-
-		response = append(response, models.NoteResponse{
-			ID:             n.ID,
-			Title:          n.Title,
-			Description:    n.Description,
-			IsPinned:       n.IsPinned,
-			IsArchived:     n.IsArchived,
-			IsChecklist:    n.IsChecklist,
-			CreatedAt:      n.CreatedAt,
-			UpdatedAt:      n.UpdatedAt,
-			CreatedBy:      n.CreatedBy,
-			UpdatedBy:      n.UpdatedBy,
-			FirstName:      user.FirstName,
-			ChecklistItems: checklist,
-			Reminders:      reminders,
-		})
+	response := []models.NoteResponse{}
+	for i := range notes {
+		response = append(response, h.buildNoteResponse(&notes[i], &user.FirstName))
 	}
 
 	result := models.NoteListResponse{
-		Notes: response,
-		Total: len(response),
+		Notes:   response,
+		Total:   total,
+		HasMore: next != nil,
+	}
+	if next != nil {
+		result.NextCursor = next.Encode()
+	}
+
+	etag := noteListETag(notes, total)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
-func (h *NoteHandler) GetNoteByID(c *gin.Context) {
-	user, err := extractUserFromHeader(c)
+func (h *NoteHandler) SearchNotes(c *gin.Context) {
+	user, err := currentUser(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	noteID, err := uuid.Parse(c.Param("id"))
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	hits, err := h.repo.Search(noteUserID(user), query, limit)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		log.Printf("Failed to search notes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not search notes"})
+		return
+	}
+
+	results := []models.NoteSearchResult{}
+	for _, hit := range hits {
+		note, err := h.repo.GetByID(hit.NoteID)
+		if err != nil {
+			continue
+		}
+		results = append(results, models.NoteSearchResult{
+			Note: h.buildNoteResponse(note, &user.FirstName),
+			Rank: hit.Rank,
+			Highlights: models.NoteSearchHighlights{
+				Title:       hit.TitleSnippet,
+				Description: hit.DescriptionSnippet,
+				Checklist:   hit.ChecklistSnippet,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, models.NoteSearchResponse{
+		Query:   query,
+		Total:   len(results),
+		Results: results,
+	})
+}
+
+func (h *NoteHandler) GetNoteByID(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.repo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", noteETag(note))
+	if etagMatches(c.GetHeader("If-None-Match"), noteETag(note)) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.buildNoteResponse(note, &user.FirstName))
+}
+
+func (h *NoteHandler) UpdateNote(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.repo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+	noteID := note.ID
+
+	if !h.checkIfMatch(c, note, &user.FirstName) {
 		return
 	}
 
-	note, err := h.repo.GetByID(noteID)
+	var req models.CreateNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reminders, err := remindersFromRequest(req.Reminders, user.Preferences.Timezone)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if note.CreatedBy != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to access this note"})
+	// Update note
+	expectedVersion := note.Version
+	wasArchived := note.IsArchived
+	note.Title = req.Title
+	note.Description = req.Description
+	note.IsPinned = req.IsPinned
+	note.IsArchived = req.IsArchived
+	note.IsChecklist = req.IsChecklist
+	note.UpdatedBy = noteUserID(user)
+	note.UpdatedAt = time.Now()
+
+	if err := h.repo.UpdateIfVersion(note, expectedVersion); err != nil {
+		if err == repositories.ErrVersionConflict {
+			h.preconditionFailed(c, noteID, &user.FirstName)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
 		return
 	}
 
-	items, _ := h.repo.GetChecklistItemsByNoteID(noteID)
-	var checklist []models.ChecklistItemResponse
-	for _, item := range items {
-		checklist = append(checklist, models.ChecklistItemResponse{
-			ID:        item.ID,
-			Text:      item.Text,
-			IsChecked: item.IsChecked,
-			CreatedAt: item.CreatedAt,
-			UpdatedAt: item.UpdatedAt,
-		})
+	// Update checklist items in place, keeping the IDs of items the client sent back
+	if err := h.repo.ReplaceChecklistItems(noteID, req.ChecklistItems); err != nil {
+		log.Printf("Checklist update error: %v", err)
 	}
 
-	remindersModel, _ := h.repo.GetRemindersByNoteID(noteID)
-	var reminders []models.ReminderResponse
-	for _, r := range remindersModel {
-		reminders = append(reminders, models.ReminderResponse{
-			Time: r.Time,
-		})
+	// Update reminders, keeping the ones whose schedule didn't change
+	if err := h.repo.ReplaceReminders(noteID, reminders); err != nil {
+		log.Printf("Reminder update error: %v", err)
 	}
 
-	response := models.NoteResponse{
-		ID:             note.ID,
+	// Replace labels (labels belong to the note owner, even when a collaborator edits)
+	if err := h.assignLabels(note.CreatedBy, noteID, req.Labels); err != nil {
+		log.Printf("Label assign error: %v", err)
+	}
+
+	// Record the new revision
+	if _, err := h.revisionRepo.Record(noteID, noteUserID(user)); err != nil {
+		log.Printf("Revision record error: %v", err)
+	}
+
+	eventType := realtime.EventNoteUpdated
+	if note.IsArchived && !wasArchived {
+		eventType = realtime.EventNoteArchived
+	}
+	h.publish(eventType, note, noteUserID(user), h.buildNoteResponse(note, &user.FirstName))
+
+	c.Header("ETag", noteETag(note))
+	c.JSON(http.StatusOK, note)
+}
+
+// PatchNote applies a partial update to a note. It accepts an RFC 7396 merge patch
+// (application/merge-patch+json or application/json) or an RFC 6902 JSON Patch
+// (application/json-patch+json) against models.NotePatchDocument; fields the patch
+// doesn't mention are left alone.
+func (h *NoteHandler) PatchNote(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.repo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	if !h.checkIfMatch(c, note, &user.FirstName) {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+		return
+	}
+
+	items, _ := h.repo.GetChecklistItemsByNoteID(note.ID)
+	reminders, _ := h.repo.GetRemindersByNoteID(note.ID)
+	labels, _ := h.repo.GetLabelsByNoteID(note.ID)
+
+	current := models.NotePatchDocument{
 		Title:          note.Title,
 		Description:    note.Description,
 		IsPinned:       note.IsPinned,
 		IsArchived:     note.IsArchived,
 		IsChecklist:    note.IsChecklist,
-		CreatedAt:      note.CreatedAt,
-		UpdatedAt:      note.UpdatedAt,
-		CreatedBy:      note.CreatedBy,
-		UpdatedBy:      note.UpdatedBy,
-		FirstName:      user.FirstName,
-		ChecklistItems: checklist,
-		Reminders:      reminders,
+		Labels:         []string{},
+		ChecklistItems: map[string]models.PatchChecklistItem{},
+		Reminders:      map[string]models.PatchReminder{},
+	}
+	for _, l := range labels {
+		current.Labels = append(current.Labels, l.Name)
+	}
+	for _, item := range items {
+		current.ChecklistItems[item.ID.String()] = models.PatchChecklistItem{ParentID: item.ParentID, Text: item.Text, IsChecked: item.IsChecked}
+	}
+	for _, r := range reminders {
+		current.Reminders[r.ID.String()] = models.PatchReminder{Time: r.Time, RRule: r.RRule, Timezone: r.Timezone}
+	}
+
+	patched, err := applyNotePatch(current, c.ContentType(), body)
+	if err == errUnsupportedPatchType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(patched.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
 	}
 
+	itemIDs, err := parsePatchKeys(patched.ChecklistItems)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePatchParents(patched.ChecklistItems); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reminderIDs, err := parsePatchKeys(patched.Reminders)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, p := range patched.Reminders {
+		if err := repositories.ValidateReminder(&models.Reminder{Time: p.Time, RRule: p.RRule, Timezone: p.Timezone}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Update note
+	expectedVersion := note.Version
+	wasArchived := note.IsArchived
+	note.Title = patched.Title
+	note.Description = patched.Description
+	note.IsPinned = patched.IsPinned
+	note.IsArchived = patched.IsArchived
+	note.IsChecklist = patched.IsChecklist
+	note.UpdatedBy = noteUserID(user)
+	note.UpdatedAt = time.Now()
+
+	if err := h.repo.UpdateIfVersion(note, expectedVersion); err != nil {
+		if err == repositories.ErrVersionConflict {
+			h.preconditionFailed(c, note.ID, &user.FirstName)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		return
+	}
+
+	// Apply checklist item changes, keeping the identity of untouched and edited items.
+	// Updates go first so sub-tasks moved out from under a removed item aren't deleted with it.
+	var toggled []uuid.UUID
+	for _, item := range items {
+		p, keep := patched.ChecklistItems[item.ID.String()]
+		if !keep {
+			continue
+		}
+		delete(itemIDs, item.ID.String())
+		if p.Text == item.Text && p.IsChecked == item.IsChecked && sameParent(p.ParentID, item.ParentID) {
+			continue
+		}
+		if p.IsChecked != item.IsChecked {
+			toggled = append(toggled, item.ID)
+		}
+		item.ParentID = p.ParentID
+		item.Text = p.Text
+		item.IsChecked = p.IsChecked
+		if err := h.repo.UpdateChecklistItem(&item); err != nil {
+			log.Printf("Checklist patch error: %v", err)
+		}
+	}
+	for _, item := range items {
+		if _, keep := patched.ChecklistItems[item.ID.String()]; keep {
+			continue
+		}
+		if err := h.repo.DeleteChecklistItem(item.ID); err != nil {
+			log.Printf("Checklist patch error: %v", err)
+		}
+	}
+	for key, id := range itemIDs {
+		p := patched.ChecklistItems[key]
+		newItem := models.ChecklistItem{ID: id, NoteID: note.ID, ParentID: p.ParentID, Text: p.Text, IsChecked: p.IsChecked}
+		if err := h.repo.CreateChecklistItem(&newItem); err != nil {
+			log.Printf("Checklist patch error: %v", err)
+		}
+	}
+	if err := h.repo.SettleChecklist(note.ID, toggled); err != nil {
+		log.Printf("Checklist patch error: %v", err)
+	}
+
+	// Apply reminder changes
+	for _, r := range reminders {
+		p, keep := patched.Reminders[r.ID.String()]
+		var err error
+		switch {
+		case !keep:
+			err = h.repo.DeleteReminder(r.ID)
+		case !p.Time.Equal(r.Time) || p.RRule != r.RRule || p.Timezone != r.Timezone:
+			r.Time = p.Time
+			r.RRule = p.RRule
+			r.Timezone = p.Timezone
+			err = h.repo.UpdateReminder(&r)
+		}
+		if err != nil {
+			log.Printf("Reminder patch error: %v", err)
+		}
+		delete(reminderIDs, r.ID.String())
+	}
+	for key, id := range reminderIDs {
+		p := patched.Reminders[key]
+		reminder := models.Reminder{ID: id, NoteID: note.ID, Time: p.Time, RRule: p.RRule, Timezone: p.Timezone}
+		if reminder.Timezone == "" {
+			reminder.Timezone = user.Preferences.Timezone
+		}
+		if err := h.repo.CreateReminder(&reminder); err != nil {
+			log.Printf("Reminder patch error: %v", err)
+		}
+	}
+
+	// Replace labels only if they changed
+	if !reflect.DeepEqual(current.Labels, patched.Labels) {
+		if err := h.assignLabels(note.CreatedBy, note.ID, patched.Labels); err != nil {
+			log.Printf("Label assign error: %v", err)
+		}
+	}
+
+	// Record the new revision
+	if _, err := h.revisionRepo.Record(note.ID, noteUserID(user)); err != nil {
+		log.Printf("Revision record error: %v", err)
+	}
+
+	response := h.buildNoteResponse(note, &user.FirstName)
+	eventType := realtime.EventNoteUpdated
+	if note.IsArchived && !wasArchived {
+		eventType = realtime.EventNoteArchived
+	}
+	h.publish(eventType, note, noteUserID(user), response)
+
+	c.Header("ETag", noteETag(note))
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *NoteHandler) UpdateNote(c *gin.Context) {
-	user, err := extractUserFromHeader(c)
+// MoveNote places a note between two neighbours in the owner's pinned or unpinned section
+func (h *NoteHandler) MoveNote(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.repo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.MoveNote(note, req.AfterID, req.BeforeID, noteUserID(user)); err != nil {
+		if err == repositories.ErrInvalidMove {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Note move error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move note"})
+		return
+	}
+
+	response := h.buildNoteResponse(note, &user.FirstName)
+	h.publish(realtime.EventNoteUpdated, note, noteUserID(user), response)
+
+	c.Header("ETag", noteETag(note))
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *NoteHandler) DeleteNote(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.repo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	if !h.checkIfMatch(c, note, &user.FirstName) {
+		return
+	}
+
+	// Move the note and its checklist items and reminders to the trash, unless it changed since the If-Match check
+	if err := h.repo.DeleteIfVersion(note.ID, note.Version); err != nil {
+		if err == repositories.ErrVersionConflict {
+			h.preconditionFailed(c, note.ID, &user.FirstName)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		return
+	}
+
+	h.broker.Publish(realtime.Event{
+		Type:       realtime.EventNoteDeleted,
+		NoteID:     note.ID,
+		Actor:      noteUserID(user),
+		Recipients: noteRecipients(h.collabRepo, note),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note moved to trash"})
+}
+
+func (h *NoteHandler) GetTrash(c *gin.Context) {
+	user, err := currentUser(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
+	notes, err := h.repo.GetTrashByUser(noteUserID(user))
+	if err != nil {
+		log.Printf("Failed to fetch trash: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch trash"})
+		return
+	}
+
+	response := []models.NoteResponse{}
+	for i := range notes {
+		response = append(response, h.buildNoteResponse(&notes[i], &user.FirstName))
+	}
+
+	c.JSON(http.StatusOK, models.NoteListResponse{
+		Notes: response,
+		Total: int64(len(response)),
+	})
+}
+
+func (h *NoteHandler) RestoreNote(c *gin.Context) {
+	user, note, ok := h.loadTrashedNote(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Restore(note); err != nil {
+		log.Printf("Failed to restore note: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore note"})
+		return
+	}
+
+	restored, err := h.repo.GetByID(note.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore note"})
+		return
+	}
+
+	response := h.buildNoteResponse(restored, &user.FirstName)
+	h.publish(realtime.EventNoteRestored, restored, noteUserID(user), response)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *NoteHandler) PurgeNote(c *gin.Context) {
+	user, note, ok := h.loadTrashedNote(c)
+	if !ok {
+		return
+	}
+
+	// Collaborators are removed with the note, so find out who to tell first
+	recipients := noteRecipients(h.collabRepo, note)
+	if err := h.repo.Purge(note.ID); err != nil {
+		log.Printf("Failed to purge note: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge note"})
+		return
+	}
+
+	h.broker.Publish(realtime.Event{
+		Type:       realtime.EventNoteDeleted,
+		NoteID:     note.ID,
+		Actor:      noteUserID(user),
+		Recipients: recipients,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note permanently deleted"})
+}
+
+// loadTrashedNote authenticates the caller and loads the trashed note in the :id param, writing an error response if it is missing or not theirs
+func (h *NoteHandler) loadTrashedNote(c *gin.Context) (*models.User, *models.Note, bool) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, nil, false
+	}
+
 	noteID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
-		return
+		return nil, nil, false
 	}
 
-	var req models.CreateNoteRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	note, err := h.repo.GetTrashedByID(noteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found in trash"})
+		return nil, nil, false
+	}
+
+	if note.CreatedBy != noteUserID(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to access this note"})
+		return nil, nil, false
+	}
+
+	return user, note, true
+}
+
+// streamTicketTTL is how long a stream ticket can be used after it was issued
+const streamTicketTTL = 30 * time.Second
+
+// CreateStreamTicket issues a single-use ticket for opening the note stream. Browsers' EventSource
+// can't set headers, and a bearer token in the URL would end up in access logs.
+func (h *NoteHandler) CreateStreamTicket(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Update note
-	note := models.Note{
-		ID:          noteID,
-		Title:       req.Title,
-		Description: req.Description,
-		IsPinned:    req.IsPinned,
-		IsArchived:  req.IsArchived,
-		IsChecklist: req.IsChecklist,
-		UpdatedBy:   user.ID,
-		UpdatedAt:   time.Now(),
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Stream ticket generation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
+	}
+	ticket := models.StreamTicket{UserID: noteUserID(user), TokenHash: hashShareToken(token), ExpiresAt: time.Now().Add(streamTicketTTL)}
+	if err := h.ticketRepo.Create(&ticket); err != nil {
+		log.Printf("Stream ticket save error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stream ticket"})
+		return
 	}
 
-	if err := h.repo.Update(&note); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+	c.JSON(http.StatusCreated, models.StreamTicketResponse{Ticket: token, ExpiresIn: int(streamTicketTTL.Seconds())})
+}
+
+// StreamNotes pushes note events for the caller over Server-Sent Events. Browsers' EventSource can't
+// set headers, so it authenticates with a ticket from CreateStreamTicket passed as ?ticket=.
+func (h *NoteHandler) StreamNotes(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Clear old checklist/reminders
-	h.repo.DeleteChecklistItemsByNote(noteID)
-	h.repo.DeleteRemindersByNote(noteID)
+	events, unsubscribe := h.broker.Subscribe(noteUserID(user))
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
 
-	// Add updated checklist items
-	for _, item := range req.ChecklistItems {
-		newItem := models.ChecklistItem{
-			ID:        uuid.New(),
-			NoteID:    noteID,
-			Text:      item.Text,
-			IsChecked: item.IsChecked,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ready", gin.H{"user": noteUserID(user)})
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"at": time.Now()})
+			return true
 		}
-		h.repo.CreateChecklistItem(&newItem)
+	})
+}
+
+// publish notifies the owner and collaborators of a note about a change
+func (h *NoteHandler) publish(eventType string, note *models.Note, actor string, response models.NoteResponse) {
+	h.broker.Publish(realtime.Event{
+		Type:       eventType,
+		NoteID:     note.ID,
+		Actor:      actor,
+		Note:       response,
+		Recipients: noteRecipients(h.collabRepo, note),
+	})
+}
+
+// noteRecipients lists every user who should receive events about a note
+func noteRecipients(collabRepo *repositories.CollaboratorRepository, note *models.Note) []string {
+	recipients := []string{note.CreatedBy}
+	collaborators, err := collabRepo.GetAllByNote(note.ID)
+	if err != nil {
+		log.Printf("Failed to load collaborators for events: %v", err)
+	}
+	for _, collaborator := range collaborators {
+		recipients = append(recipients, collaborator.UserID)
 	}
+	return recipients
+}
+
+var errUnsupportedPatchType = errors.New("Content-Type must be application/merge-patch+json or application/json-patch+json")
 
-	// Add updated reminders
-	for _, r := range req.Reminders {
-		reminder := models.Reminder{
-			ID:     uuid.New(),
-			NoteID: noteID,
-			Time:   r.Time,
+// applyNotePatch applies a merge patch or JSON Patch body to a note document
+func applyNotePatch(current models.NotePatchDocument, contentType string, body []byte) (*models.NotePatchDocument, error) {
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	switch contentType {
+	case "application/json-patch+json":
+		var ops []jsonpatch.Operation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, fmt.Errorf("invalid JSON Patch: %v", err)
+		}
+		if doc, err = jsonpatch.Apply(doc, ops); err != nil {
+			return nil, err
 		}
-		h.repo.CreateReminder(&reminder)
+	case "application/merge-patch+json", "application/json":
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, fmt.Errorf("invalid merge patch: %v", err)
+		}
+		doc = jsonpatch.MergePatch(doc, patch)
+	default:
+		return nil, errUnsupportedPatchType
 	}
 
-	c.JSON(http.StatusOK, note)
+	if raw, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var patched models.NotePatchDocument
+	if err := dec.Decode(&patched); err != nil {
+		return nil, fmt.Errorf("patched note is invalid: %v", err)
+	}
+	return &patched, nil
 }
 
-func (h *NoteHandler) DeleteNote(c *gin.Context) {
+// parsePatchKeys checks every key of a patched checklist/reminder map is a UUID
+func parsePatchKeys[T any](entries map[string]T) (map[string]uuid.UUID, error) {
+	ids := make(map[string]uuid.UUID, len(entries))
+	for key := range entries {
+		id, err := uuid.Parse(key)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid ID", key)
+		}
+		ids[key] = id
+	}
+	return ids, nil
+}
+
+// validatePatchParents checks every parent_id in a patched checklist names another item of
+// the same checklist and that following parents never loops back
+func validatePatchParents(items map[string]models.PatchChecklistItem) error {
+	for key, item := range items {
+		steps := 0
+		for parent := item.ParentID; parent != nil; parent = items[parent.String()].ParentID {
+			if _, ok := items[parent.String()]; !ok || parent.String() == key || steps > len(items) {
+				return fmt.Errorf("checklist item %s has an invalid parent_id", key)
+			}
+			steps++
+		}
+	}
+	return nil
+}
+
+// checkIfMatch requires writes to name the version they were based on in If-Match, writing
+// 428 when it is missing and 412 with the current server copy when it is stale
+func (h *NoteHandler) checkIfMatch(c *gin.Context, note *models.Note, firstName *string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return false
+	}
+	if !etagMatches(ifMatch, noteETag(note)) {
+		c.Header("ETag", noteETag(note))
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error":   "Note has been modified",
+			"current": h.buildNoteResponse(note, firstName),
+		})
+		return false
+	}
+	return true
+}
+
+// preconditionFailed reloads a note that changed underneath a write and returns it with 412
+func (h *NoteHandler) preconditionFailed(c *gin.Context, noteID uuid.UUID, firstName *string) {
+	current, err := h.repo.GetByID(noteID)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Note has been modified"})
+		return
+	}
+	c.Header("ETag", noteETag(current))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "Note has been modified",
+		"current": h.buildNoteResponse(current, firstName),
+	})
+}
+
+func noteETag(note *models.Note) string {
+	return fmt.Sprintf("%q", strconv.Itoa(note.Version))
+}
+
+// noteListETag fingerprints a page of notes by ID and version
+func noteListETag(notes []models.Note, total int64) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d", total)
+	for _, n := range notes {
+		fmt.Fprintf(hash, "|%s:%d", n.ID, n.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum(nil)[:16])
+}
+
+// etagMatches reports whether an If-Match/If-None-Match header value lists etag (or is *)
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// noteRoleRank orders note roles so a required role can be checked with a comparison
+var noteRoleRank = map[string]int{
+	models.NoteRoleViewer: 1,
+	models.NoteRoleEditor: 2,
+	models.NoteRoleOwner:  3,
+}
+
+// loadAuthorizedNote authenticates the caller and loads the note in the :id param, writing an error response
+// if it is missing or the caller's role on it (owner or collaborator) is below required
+func loadAuthorizedNote(c *gin.Context, notes *repositories.NoteRepository, collaborators *repositories.CollaboratorRepository, required string) (*models.User, *models.Note, bool) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, nil, false
+	}
+
 	noteID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
-		return
+		return nil, nil, false
+	}
+
+	note, err := notes.GetByID(noteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return nil, nil, false
 	}
 
-	// Delete related checklist and reminders first
-	h.repo.DeleteChecklistItemsByNote(noteID)
-	h.repo.DeleteRemindersByNote(noteID)
+	if !authorizeNote(c, collaborators, note, noteUserID(user), required) {
+		return nil, nil, false
+	}
+	return user, note, true
+}
 
-	// Then delete the note
-	if err := h.repo.Delete(noteID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
-		return
+// authorizeNote checks the user's role on a note is at least required, writing an error response if not
+func authorizeNote(c *gin.Context, collaborators *repositories.CollaboratorRepository, note *models.Note, userID string, required string) bool {
+	role, err := collaborators.RoleFor(note, userID)
+	if err != nil {
+		log.Printf("Failed to resolve note role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check note access"})
+		return false
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
+	if noteRoleRank[role] < noteRoleRank[required] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to access this note"})
+		return false
+	}
+	return true
+}
+
+// assignLabels resolves label names for the user (creating new ones as needed) and attaches them to the note
+func (h *NoteHandler) assignLabels(userID string, noteID uuid.UUID, names []string) error {
+	labels, err := h.labelRepo.FindOrCreateByNames(userID, names)
+	if err != nil {
+		return err
+	}
+	return h.repo.ReplaceLabels(noteID, labels)
+}
+
+// buildNoteResponse loads a note's checklist items, reminders and labels and maps them to the response model
+func (h *NoteHandler) buildNoteResponse(note *models.Note, firstName *string) models.NoteResponse {
+	var (
+		items          []models.ChecklistItem
+		remindersModel []models.Reminder
+		deletedAt      *time.Time
+	)
+	if note.DeletedAt.Valid {
+		deletedAt = &note.DeletedAt.Time
+		items, _ = h.repo.GetTrashedChecklistItemsByNoteID(note.ID, note.DeletedAt.Time)
+		remindersModel, _ = h.repo.GetTrashedRemindersByNoteID(note.ID, note.DeletedAt.Time)
+	} else {
+		items, _ = h.repo.GetChecklistItemsByNoteID(note.ID)
+		remindersModel, _ = h.repo.GetRemindersByNoteID(note.ID)
+	}
+
+	var checklist []models.ChecklistItemResponse
+	if len(items) > 0 {
+		checklist = toChecklistTree(items)
+	}
+
+	var reminders []models.ReminderResponse
+	for i := range remindersModel {
+		reminders = append(reminders, toReminderResponse(h.reminderRepo, &remindersModel[i]))
+	}
+
+	labelsModel, _ := h.repo.GetLabelsByNoteID(note.ID)
+	var labels []string
+	for _, l := range labelsModel {
+		labels = append(labels, l.Name)
+	}
+
+	return models.NoteResponse{
+		ID:             note.ID,
+		Title:          note.Title,
+		Description:    note.Description,
+		IsPinned:       note.IsPinned,
+		IsArchived:     note.IsArchived,
+		IsChecklist:    note.IsChecklist,
+		Version:        note.Version,
+		Rank:           note.Rank,
+		Completion:     checklistCompletion(items),
+		CreatedAt:      note.CreatedAt,
+		UpdatedAt:      note.UpdatedAt,
+		CreatedBy:      note.CreatedBy,
+		UpdatedBy:      note.UpdatedBy,
+		DeletedAt:      deletedAt,
+		FirstName:      firstName,
+		ChecklistItems: checklist,
+		Reminders:      reminders,
+		Labels:         labels,
+	}
+}
+
+// parseNoteListOptions reads pagination, sort and filter query parameters for GET /notes, sorting
+// by defaultSort (the user's preference) unless asked otherwise
+func parseNoteListOptions(c *gin.Context, defaultSort string) (repositories.NoteListOptions, error) {
+	opts := repositories.NoteListOptions{
+		Label: c.Query("label"),
+		Sort:  c.DefaultQuery("sort", defaultSort),
+		Limit: 50,
+	}
+
+	switch opts.Sort {
+	case repositories.NoteSortUpdatedAt, repositories.NoteSortCreatedAt:
+		opts.Descending = true
+	case repositories.NoteSortTitle, repositories.NoteSortRank:
+	default:
+		return opts, fmt.Errorf("sort must be one of updated_at, created_at, title, rank")
+	}
+
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			return opts, fmt.Errorf("limit must be between 1 and 100")
+		}
+		opts.Limit = limit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := repositories.DecodeNoteCursor(cursor)
+		if err != nil {
+			return opts, fmt.Errorf("invalid cursor")
+		}
+		opts.Cursor = decoded
+	}
+
+	pinnedFirst, err := queryBool(c, "pinnedFirst")
+	if err != nil {
+		return opts, err
+	}
+	// Ranks order notes within the pinned and unpinned sections, so the manual order lists pinned notes first
+	opts.PinnedFirst = opts.Sort == repositories.NoteSortRank
+	if pinnedFirst != nil {
+		opts.PinnedFirst = *pinnedFirst
+	}
+
+	if opts.IsPinned, err = queryBool(c, "isPinned"); err != nil {
+		return opts, err
+	}
+	if opts.IsArchived, err = queryBool(c, "isArchived"); err != nil {
+		return opts, err
+	}
+	if opts.IsChecklist, err = queryBool(c, "isChecklist"); err != nil {
+		return opts, err
+	}
+
+	times := map[string]**time.Time{
+		"createdAfter":  &opts.CreatedAfter,
+		"createdBefore": &opts.CreatedBefore,
+		"updatedAfter":  &opts.UpdatedAfter,
+		"updatedBefore": &opts.UpdatedBefore,
+	}
+	for name, dst := range times {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+		}
+		*dst = &t
+	}
+
+	return opts, nil
+}
+
+// queryBool parses an optional boolean query parameter, returning nil when it is absent
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &b, nil
 }
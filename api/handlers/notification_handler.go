@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	repo *repositories.NotificationRepository
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{
+		repo: repositories.NewNotificationRepository(db),
+	}
+}
+
+// ListNotifications returns the caller's in-app notifications, newest first: GET /notifications?unread=true&limit=50
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	unread, err := queryBool(c, "unread")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+	}
+
+	notifications, err := h.repo.GetAllByUser(noteUserID(user), unread != nil && *unread, limit)
+	if err != nil {
+		log.Printf("Failed to fetch notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch notifications"})
+		return
+	}
+
+	response := []models.NotificationResponse{}
+	for i := range notifications {
+		response = append(response, toNotificationResponse(&notifications[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	notification, err := h.repo.MarkRead(id, noteUserID(user))
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to mark notification read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toNotificationResponse(notification))
+}
+
+func toNotificationResponse(notification *models.Notification) models.NotificationResponse {
+	return models.NotificationResponse{
+		ID:         notification.ID,
+		NoteID:     notification.NoteID,
+		ReminderID: notification.ReminderID,
+		Title:      notification.Title,
+		Body:       notification.Body,
+		ReadAt:     notification.ReadAt,
+		CreatedAt:  notification.CreatedAt,
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PreferencesHandler struct {
+	userRepo *repositories.UserRepository
+}
+
+func NewPreferencesHandler(db *gorm.DB) *PreferencesHandler {
+	return &PreferencesHandler{
+		userRepo: repositories.NewUserRepository(db),
+	}
+}
+
+// GetPreferences returns the caller's preferences, the defaults until they've saved any
+func (h *PreferencesHandler) GetPreferences(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Preferences)
+}
+
+// UpdatePreferences replaces the caller's preferences
+func (h *PreferencesHandler) UpdatePreferences(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs := models.UserPreferences{
+		Timezone:            req.Timezone,
+		WeekStart:           req.WeekStart,
+		DefaultNoteSort:     req.DefaultNoteSort,
+		DefaultReminderTime: req.DefaultReminderTime,
+		Theme:               req.Theme,
+	}
+	if err := repositories.ValidatePreferences(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Clerk users only get a row once they have something to store
+	if user.ID == uuid.Nil {
+		if err := h.userRepo.EnsureClerkUser(user); err != nil {
+			log.Printf("Failed to load user: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save preferences"})
+			return
+		}
+	}
+	if err := h.userRepo.UpdatePreferences(user, prefs); err != nil {
+		log.Printf("Failed to save preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, user.Preferences)
+}
+
+// userPreferences returns another user's preferences, e.g. a calendar feed owner's, falling back
+// to the defaults if they can't be loaded
+func userPreferences(repo *repositories.UserRepository, userID string) models.UserPreferences {
+	prefs, err := repo.GetPreferences(userID)
+	if err != nil {
+		log.Printf("Failed to fetch preferences: %v", err)
+	}
+	return prefs
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultUpcomingOccurrences is how many occurrences reminder responses list
+	defaultUpcomingOccurrences = 5
+	maxUpcomingOccurrences     = 100
+)
+
+type ReminderHandler struct {
+	noteRepo     *repositories.NoteRepository
+	reminderRepo *repositories.ReminderRepository
+	collabRepo   *repositories.CollaboratorRepository
+	broker       realtime.Broker
+}
+
+func NewReminderHandler(db *gorm.DB, broker realtime.Broker) *ReminderHandler {
+	return &ReminderHandler{
+		noteRepo:     repositories.NewNoteRepository(db),
+		reminderRepo: repositories.NewReminderRepository(db),
+		collabRepo:   repositories.NewCollaboratorRepository(db),
+		broker:       broker,
+	}
+}
+
+// GetReminder returns a reminder with its upcoming occurrences; ?count= sets how many
+func (h *ReminderHandler) GetReminder(c *gin.Context) {
+	_, _, reminder, ok := h.loadReminder(c, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(defaultUpcomingOccurrences)))
+	if err != nil || count < 1 || count > maxUpcomingOccurrences {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be between 1 and " + strconv.Itoa(maxUpcomingOccurrences)})
+		return
+	}
+
+	response := toReminderResponse(h.reminderRepo, reminder)
+	response.Upcoming, err = h.reminderRepo.Upcoming(reminder, time.Time{}, count)
+	if err != nil {
+		log.Printf("Reminder occurrences error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not compute reminder occurrences"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SkipOccurrence drops a single occurrence of a recurring reminder
+func (h *ReminderHandler) SkipOccurrence(c *gin.Context) {
+	user, note, reminder, ok := h.loadReminder(c, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	var req models.SkipOccurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.overrideOccurrence(c, user, note, reminder, req.Occurrence, nil)
+}
+
+// RescheduleOccurrence moves a single occurrence of a recurring reminder, leaving the rest of the series as is
+func (h *ReminderHandler) RescheduleOccurrence(c *gin.Context) {
+	user, note, reminder, ok := h.loadReminder(c, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	var req models.RescheduleOccurrenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.overrideOccurrence(c, user, note, reminder, req.Occurrence, &req.Time)
+}
+
+func (h *ReminderHandler) overrideOccurrence(c *gin.Context, user *models.User, note *models.Note, reminder *models.Reminder, occurrence time.Time, moved *time.Time) {
+	err := h.reminderRepo.OverrideOccurrence(reminder, occurrence, moved)
+	if errors.Is(err, repositories.ErrNotRecurring) || errors.Is(err, repositories.ErrNotAnOccurrence) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Reminder override error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update reminder"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, toReminderResponse(h.reminderRepo, reminder))
+}
+
+// ListUpcoming returns the occurrences due in the next ?days= days (7 by default) across every
+// note the user owns or collaborates on, soonest first: GET /reminders/upcoming?days=7&limit=50
+func (h *ReminderHandler) ListUpcoming(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > maxUpcomingOccurrences {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxUpcomingOccurrences)})
+		return
+	}
+
+	reminders, err := h.reminderRepo.GetActiveByUser(noteUserID(user))
+	if err != nil {
+		log.Printf("Failed to fetch reminders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reminders"})
+		return
+	}
+
+	until := time.Now().AddDate(0, 0, days)
+	response := []models.UpcomingReminderResponse{}
+	for i := range reminders {
+		reminder := &reminders[i]
+		times, err := h.reminderRepo.Upcoming(reminder, until, limit)
+		if err != nil {
+			log.Printf("Reminder occurrences error: %v", err)
+			continue
+		}
+		for j, t := range times {
+			status := reminder.Status
+			if j > 0 || status == models.ReminderStatusFired || status == models.ReminderStatusDismissed || status == models.ReminderStatusMissed {
+				// The reminder's status belongs to an occurrence that is already over
+				status = models.ReminderStatusPending
+			}
+			response = append(response, models.UpcomingReminderResponse{
+				ReminderID: reminder.ID,
+				NoteID:     reminder.NoteID,
+				NoteTitle:  reminder.Note.Title,
+				Time:       t,
+				Status:     status,
+				Recurring:  reminder.RRule != "",
+			})
+		}
+	}
+
+	sort.Slice(response, func(i, j int) bool { return response[i].Time.Before(response[j].Time) })
+	if len(response) > limit {
+		response = response[:limit]
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Snooze puts off a reminder that has fired, by a preset (10m, 1h, 3h, tomorrow, next_week) or until a given time
+func (h *ReminderHandler) Snooze(c *gin.Context) {
+	user, note, reminder, ok := h.loadReminderByID(c, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	var req models.SnoozeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Preset == "") == (req.Until == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either a preset or an until time"})
+		return
+	}
+	if reminder.Status == models.ReminderStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reminder hasn't fired yet"})
+		return
+	}
+
+	until := req.Until
+	if req.Preset != "" {
+		prefs := user.Preferences
+		loc := repositories.PreferredLocation(prefs)
+		if reminder.Timezone != "" {
+			if l, err := repositories.ReminderLocation(reminder.Timezone); err == nil {
+				loc = l
+			}
+		}
+		t := snoozePreset(req.Preset, time.Now().In(loc), prefs)
+		until = &t
+	}
+	if !until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until must be in the future"})
+		return
+	}
+
+	if err := h.reminderRepo.Snooze(reminder, *until); err != nil {
+		log.Printf("Reminder snooze error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not snooze reminder"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, toReminderResponse(h.reminderRepo, reminder))
+}
+
+// Dismiss acknowledges a reminder; one that hasn't fired yet is cancelled (for a recurring
+// reminder, just its next occurrence)
+func (h *ReminderHandler) Dismiss(c *gin.Context) {
+	user, note, reminder, ok := h.loadReminderByID(c, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := h.reminderRepo.Dismiss(reminder); err != nil {
+		log.Printf("Reminder dismiss error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not dismiss reminder"})
+		return
+	}
+
+	h.noteChanged(note, user)
+	c.JSON(http.StatusOK, toReminderResponse(h.reminderRepo, reminder))
+}
+
+// snoozePreset resolves a snooze preset relative to now; "tomorrow" and "next_week" (the start of
+// the user's next week) mean the user's default reminder time on that day in now's location
+func snoozePreset(preset string, now time.Time, prefs models.UserPreferences) time.Time {
+	hour, minute := repositories.PreferredReminderClock(prefs)
+	at := func(days int) time.Time {
+		y, m, d := now.Date()
+		return time.Date(y, m, d+days, hour, minute, 0, 0, now.Location())
+	}
+	switch preset {
+	case "10m":
+		return now.Add(10 * time.Minute)
+	case "1h":
+		return now.Add(time.Hour)
+	case "3h":
+		return now.Add(3 * time.Hour)
+	case "tomorrow":
+		return at(1)
+	default: // next_week
+		return at(7 - (int(now.Weekday())-int(repositories.PreferredWeekStart(prefs))+7)%7)
+	}
+}
+
+// noteChanged bumps the version of the reminder's note and notifies everyone on it
+func (h *ReminderHandler) noteChanged(note *models.Note, user *models.User) {
+	if err := h.noteRepo.Touch(note, noteUserID(user)); err != nil {
+		log.Printf("Note touch error: %v", err)
+	}
+	h.broker.Publish(realtime.Event{
+		Type:       realtime.EventNoteUpdated,
+		NoteID:     note.ID,
+		Actor:      noteUserID(user),
+		Recipients: noteRecipients(h.collabRepo, note),
+	})
+}
+
+// loadReminder loads the :reminderId reminder of the :id note, checking the caller's role on the note
+func (h *ReminderHandler) loadReminder(c *gin.Context, required string) (*models.User, *models.Note, *models.Reminder, bool) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, required)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	reminderID, err := uuid.Parse(c.Param("reminderId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return nil, nil, nil, false
+	}
+
+	reminder, err := h.noteRepo.GetReminder(note.ID, reminderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return nil, nil, nil, false
+	}
+
+	return user, note, reminder, true
+}
+
+// loadReminderByID loads the :id reminder and its live note, checking the caller's role on the note
+func (h *ReminderHandler) loadReminderByID(c *gin.Context, required string) (*models.User, *models.Note, *models.Reminder, bool) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, nil, nil, false
+	}
+
+	reminderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return nil, nil, nil, false
+	}
+
+	reminder, err := h.noteRepo.GetReminderByID(reminderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return nil, nil, nil, false
+	}
+	note, err := h.noteRepo.GetByID(reminder.NoteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return nil, nil, nil, false
+	}
+
+	if !authorizeNote(c, h.collabRepo, note, noteUserID(user), required) {
+		return nil, nil, nil, false
+	}
+	return user, note, reminder, true
+}
+
+// toReminderResponse maps a reminder to the response model, listing its next few occurrences
+func toReminderResponse(reminderRepo *repositories.ReminderRepository, reminder *models.Reminder) models.ReminderResponse {
+	response := models.ReminderResponse{
+		ID:       reminder.ID,
+		Time:     reminder.Time,
+		RRule:    reminder.RRule,
+		Timezone: reminder.Timezone,
+		Status:   reminder.Status,
+	}
+	if reminder.Status == models.ReminderStatusSnoozed && reminder.DeliveredAt == nil {
+		response.SnoozedUntil = reminder.DueAt
+	}
+	if reminder.RRule != "" {
+		upcoming, err := reminderRepo.Upcoming(reminder, time.Time{}, defaultUpcomingOccurrences)
+		if err != nil {
+			log.Printf("Reminder occurrences error: %v", err)
+		}
+		response.Upcoming = upcoming
+	}
+	return response
+}
+
+// remindersFromRequest converts the reminders sent with a note, rejecting invalid recurrence rules and
+// time zones. Reminders without a time zone get defaultTimezone, the user's.
+func remindersFromRequest(reqs []models.ReminderRequest, defaultTimezone string) ([]models.Reminder, error) {
+	reminders := make([]models.Reminder, 0, len(reqs))
+	for _, r := range reqs {
+		reminder := models.Reminder{Time: r.Time, RRule: r.RRule, Timezone: r.Timezone}
+		if reminder.Timezone == "" {
+			reminder.Timezone = defaultTimezone
+		}
+		if err := repositories.ValidateReminder(&reminder); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, nil
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RevisionHandler struct {
+	noteRepo     *repositories.NoteRepository
+	revisionRepo *repositories.RevisionRepository
+	collabRepo   *repositories.CollaboratorRepository
+	broker       realtime.Broker
+}
+
+func NewRevisionHandler(db *gorm.DB, broker realtime.Broker) *RevisionHandler {
+	return &RevisionHandler{
+		noteRepo:     repositories.NewNoteRepository(db),
+		revisionRepo: repositories.NewRevisionRepository(db),
+		collabRepo:   repositories.NewCollaboratorRepository(db),
+		broker:       broker,
+	}
+}
+
+func (h *RevisionHandler) ListRevisions(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisionRepo.GetAllByNote(note.ID)
+	if err != nil {
+		log.Printf("Failed to fetch revisions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch revisions"})
+		return
+	}
+
+	response := []models.NoteRevisionResponse{}
+	for i := range revisions {
+		response = append(response, toRevisionResponse(&revisions[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *RevisionHandler) GetRevision(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	rev, ok := h.loadRevision(c, note.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toRevisionResponse(rev))
+}
+
+// DiffRevisions compares two revisions field by field: GET /notes/:id/revisions/diff?from=1&to=2
+func (h *RevisionHandler) DiffRevisions(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleViewer)
+	if !ok {
+		return
+	}
+
+	from, ok := h.loadRevision(c, note.ID, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := h.loadRevision(c, note.ID, c.Query("to"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, diffRevisions(from, to))
+}
+
+func (h *RevisionHandler) RestoreRevision(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleEditor)
+	if !ok {
+		return
+	}
+
+	rev, ok := h.loadRevision(c, note.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	if err := h.noteRepo.ApplyRevision(rev, noteUserID(user)); err != nil {
+		log.Printf("Failed to restore revision: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	h.broker.Publish(realtime.Event{
+		Type:       realtime.EventNoteUpdated,
+		NoteID:     note.ID,
+		Actor:      noteUserID(user),
+		Recipients: noteRecipients(h.collabRepo, note),
+	})
+
+	// The rollback is itself an edit, so it gets a revision of its own
+	latest, err := h.revisionRepo.Record(note.ID, noteUserID(user))
+	if err != nil {
+		log.Printf("Revision record error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toRevisionResponse(latest))
+}
+
+// loadRevision parses a revision number and loads it, writing an error response on failure
+func (h *RevisionHandler) loadRevision(c *gin.Context, noteID uuid.UUID, number string) (*models.NoteRevision, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return nil, false
+	}
+
+	rev, err := h.revisionRepo.GetByNumber(noteID, n)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, false
+	}
+
+	return rev, true
+}
+
+func toRevisionResponse(rev *models.NoteRevision) models.NoteRevisionResponse {
+	checklist := []models.ChecklistSnapshotItem(rev.Checklist)
+	if checklist == nil {
+		checklist = []models.ChecklistSnapshotItem{}
+	}
+	return models.NoteRevisionResponse{
+		Revision:    rev.Revision,
+		Title:       rev.Title,
+		Description: rev.Description,
+		IsPinned:    rev.IsPinned,
+		IsArchived:  rev.IsArchived,
+		IsChecklist: rev.IsChecklist,
+		Checklist:   checklist,
+		EditedBy:    rev.EditedBy,
+		CreatedAt:   rev.CreatedAt,
+	}
+}
+
+// diffRevisions lists the note fields and checklist items that differ between two revisions
+func diffRevisions(from, to *models.NoteRevision) models.NoteRevisionDiffResponse {
+	diff := models.NoteRevisionDiffResponse{
+		From:      from.Revision,
+		To:        to.Revision,
+		Fields:    []models.FieldChange{},
+		Checklist: []models.ChecklistItemChange{},
+	}
+
+	addField := func(field string, a, b interface{}) {
+		if a != b {
+			diff.Fields = append(diff.Fields, models.FieldChange{Field: field, From: a, To: b})
+		}
+	}
+	addField("title", from.Title, to.Title)
+	addField("description", from.Description, to.Description)
+	addField("isPinned", from.IsPinned, to.IsPinned)
+	addField("isArchived", from.IsArchived, to.IsArchived)
+	addField("isChecklist", from.IsChecklist, to.IsChecklist)
+
+	before := make(map[uuid.UUID]models.ChecklistSnapshotItem)
+	for _, item := range from.Checklist {
+		before[item.ID] = item
+	}
+	after := make(map[uuid.UUID]bool)
+	for _, item := range to.Checklist {
+		item := item
+		after[item.ID] = true
+		old, existed := before[item.ID]
+		switch {
+		case !existed:
+			diff.Checklist = append(diff.Checklist, models.ChecklistItemChange{ID: item.ID, Change: "added", To: &item})
+		case old != item:
+			diff.Checklist = append(diff.Checklist, models.ChecklistItemChange{ID: item.ID, Change: "modified", From: &old, To: &item})
+		}
+	}
+	for _, item := range from.Checklist {
+		item := item
+		if !after[item.ID] {
+			diff.Checklist = append(diff.Checklist, models.ChecklistItemChange{ID: item.ID, Change: "removed", From: &item})
+		}
+	}
+
+	return diff
+}
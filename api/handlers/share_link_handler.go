@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type ShareLinkHandler struct {
+	noteRepo   *repositories.NoteRepository
+	collabRepo *repositories.CollaboratorRepository
+	linkRepo   *repositories.ShareLinkRepository
+}
+
+func NewShareLinkHandler(db *gorm.DB) *ShareLinkHandler {
+	return &ShareLinkHandler{
+		noteRepo:   repositories.NewNoteRepository(db),
+		collabRepo: repositories.NewCollaboratorRepository(db),
+		linkRepo:   repositories.NewShareLinkRepository(db),
+	}
+}
+
+func (h *ShareLinkHandler) CreateShareLink(c *gin.Context) {
+	user, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("Share token generation error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create share link"})
+		return
+	}
+
+	link := models.NoteShareLink{
+		ID:        uuid.New(),
+		NoteID:    note.ID,
+		TokenHash: hashShareToken(token),
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: noteUserID(user),
+	}
+
+	if req.Password != "" {
+		pw, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Println("Password hashing error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		link.PasswordHash = string(pw)
+	}
+
+	if err := h.linkRepo.Create(&link); err != nil {
+		log.Printf("Failed to create share link: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create share link"})
+		return
+	}
+
+	// The raw token is only ever returned here; we keep just its hash
+	response := toShareLinkResponse(&link)
+	response.Token = token
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *ShareLinkHandler) ListShareLinks(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	links, err := h.linkRepo.GetAllByNote(note.ID)
+	if err != nil {
+		log.Printf("Failed to fetch share links: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch share links"})
+		return
+	}
+
+	response := []models.ShareLinkResponse{}
+	for i := range links {
+		response = append(response, toShareLinkResponse(&links[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ShareLinkHandler) RevokeShareLink(c *gin.Context) {
+	_, note, ok := loadAuthorizedNote(c, h.noteRepo, h.collabRepo, models.NoteRoleOwner)
+	if !ok {
+		return
+	}
+
+	linkID, err := uuid.Parse(c.Param("linkId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share link ID"})
+		return
+	}
+
+	link, err := h.linkRepo.GetByID(linkID)
+	if err != nil || link.NoteID != note.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if err := h.linkRepo.Revoke(link.ID); err != nil {
+		log.Printf("Failed to revoke share link: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// GetSharedNote renders a note for anyone holding an active share link. Password-protected
+// links expect the password in the X-Share-Password header.
+func (h *ShareLinkHandler) GetSharedNote(c *gin.Context) {
+	link, err := h.linkRepo.GetActiveByTokenHash(hashShareToken(c.Param("token")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared note not found"})
+		return
+	}
+
+	if link.PasswordHash != "" {
+		password := c.GetHeader("X-Share-Password")
+		if password == "" || bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password required"})
+			return
+		}
+	}
+
+	note, err := h.noteRepo.GetByID(link.NoteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared note not found"})
+		return
+	}
+
+	items, _ := h.noteRepo.GetChecklistItemsByNoteID(note.ID)
+	var checklist []models.ChecklistItemResponse
+	if len(items) > 0 {
+		checklist = toChecklistTree(items)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.SharedNoteResponse{
+		Title:          note.Title,
+		Description:    note.Description,
+		IsChecklist:    note.IsChecklist,
+		ChecklistItems: checklist,
+		UpdatedAt:      note.UpdatedAt,
+	})
+}
+
+func generateShareToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toShareLinkResponse(link *models.NoteShareLink) models.ShareLinkResponse {
+	return models.ShareLinkResponse{
+		ID:          link.ID,
+		ExpiresAt:   link.ExpiresAt,
+		HasPassword: link.PasswordHash != "",
+		RevokedAt:   link.RevokedAt,
+		CreatedAt:   link.CreatedAt,
+	}
+}
@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"todo-backend/config"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// syncOverlap is subtracted from issued sync tokens so rows written by transactions that were still
+// in flight when the token was issued are picked up by the next pull. Clients apply changes idempotently.
+const syncOverlap = 5 * time.Second
+
+const (
+	syncStatusApplied   = "applied"
+	syncStatusConflict  = "conflict"
+	syncStatusNotFound  = "not_found"
+	syncStatusForbidden = "forbidden"
+	syncStatusInvalid   = "invalid"
+)
+
+type SyncHandler struct {
+	syncRepo     *repositories.SyncRepository
+	noteRepo     *repositories.NoteRepository
+	collabRepo   *repositories.CollaboratorRepository
+	revisionRepo *repositories.RevisionRepository
+	broker       realtime.Broker
+	retention    time.Duration
+}
+
+func NewSyncHandler(db *gorm.DB, broker realtime.Broker) *SyncHandler {
+	return &SyncHandler{
+		syncRepo:     repositories.NewSyncRepository(db),
+		noteRepo:     repositories.NewNoteRepository(db),
+		collabRepo:   repositories.NewCollaboratorRepository(db),
+		revisionRepo: repositories.NewRevisionRepository(db),
+		broker:       broker,
+		retention:    config.TrashRetention(),
+	}
+}
+
+// Pull returns everything changed since the token in ?since=. Without a token, or with one older than
+// the trash retention (deletions may already be purged), it returns a full snapshot instead.
+func (h *SyncHandler) Pull(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	issuedAt := time.Now()
+
+	var since time.Time
+	if token := c.Query("since"); token != "" {
+		since, err = decodeSyncToken(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync token"})
+			return
+		}
+		if since.Before(issuedAt.Add(-h.retention)) {
+			since = time.Time{}
+		}
+	}
+
+	changes, err := h.syncRepo.ChangesSince(noteUserID(user), since)
+	if err != nil {
+		log.Printf("Failed to load sync changes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load changes"})
+		return
+	}
+
+	response := models.SyncPullResponse{
+		Token:          encodeSyncToken(issuedAt.Add(-syncOverlap)),
+		Full:           since.IsZero(),
+		Notes:          []models.SyncNote{},
+		ChecklistItems: []models.SyncChecklistItem{},
+		Reminders:      []models.SyncReminder{},
+	}
+	for i := range changes.Notes {
+		response.Notes = append(response.Notes, toSyncNote(&changes.Notes[i]))
+	}
+	for i := range changes.ChecklistItems {
+		response.ChecklistItems = append(response.ChecklistItems, toSyncChecklistItem(&changes.ChecklistItems[i]))
+	}
+	for i := range changes.Reminders {
+		response.Reminders = append(response.Reminders, toSyncReminder(&changes.Reminders[i]))
+	}
+	// A note the user can no longer access looks deleted to them
+	for _, revoked := range changes.Revoked {
+		revokedAt := revoked.RevokedAt
+		response.Notes = append(response.Notes, models.SyncNote{ID: revoked.NoteID, UpdatedAt: revokedAt, DeletedAt: &revokedAt})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Push applies a batch of client mutations in order and reports the outcome of each one.
+// A failed mutation doesn't stop the rest of the batch.
+func (h *SyncHandler) Push(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	issuedAt := time.Now()
+	touched := make(map[uuid.UUID]*syncTouch)
+	results := make([]models.SyncMutationResult, 0, len(req.Mutations))
+	for _, m := range req.Mutations {
+		var result models.SyncMutationResult
+		switch m.Entity {
+		case "note":
+			result = h.applyNote(user, m, touched)
+		case "checklist_item":
+			result = h.applyChecklistItem(user, m, touched)
+		case "reminder":
+			result = h.applyReminder(user, m, touched)
+		}
+		result.Entity = m.Entity
+		result.ID = m.ID
+		results = append(results, result)
+	}
+
+	for noteID, touch := range touched {
+		note, err := h.noteRepo.GetByID(noteID)
+		if err != nil {
+			note, err = h.noteRepo.GetTrashedByID(noteID)
+			if err != nil {
+				continue
+			}
+		}
+		if touch.event != realtime.EventNoteDeleted {
+			// The note's version is its ETag, so it has to change with its checklist and reminders too
+			if touch.children {
+				if err := h.noteRepo.Touch(note, noteUserID(user)); err != nil {
+					log.Printf("Note touch error: %v", err)
+				}
+			}
+			if _, err := h.revisionRepo.Record(noteID, noteUserID(user)); err != nil {
+				log.Printf("Revision record error: %v", err)
+			}
+		}
+		h.broker.Publish(realtime.Event{
+			Type:       touch.event,
+			NoteID:     noteID,
+			Actor:      noteUserID(user),
+			Recipients: noteRecipients(h.collabRepo, note),
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SyncPushResponse{
+		Token:   encodeSyncToken(issuedAt.Add(-syncOverlap)),
+		Results: results,
+	})
+}
+
+func (h *SyncHandler) applyNote(user *models.User, m models.SyncMutation, touched map[uuid.UUID]*syncTouch) models.SyncMutationResult {
+	note, err := h.noteRepo.GetByID(m.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if m.Op == "delete" {
+			return models.SyncMutationResult{Status: syncStatusNotFound}
+		}
+		if trashed, _ := h.noteRepo.GetTrashedByID(m.ID); trashed != nil {
+			return models.SyncMutationResult{Status: syncStatusConflict, Error: "note is in the trash"}
+		}
+		if m.Data.Title == nil || strings.TrimSpace(*m.Data.Title) == "" {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "title is required"}
+		}
+
+		note = &models.Note{ID: m.ID, Version: 1, CreatedBy: noteUserID(user), CreatedAt: time.Now()}
+		applyNoteData(note, m.Data)
+		note.UpdatedBy = noteUserID(user)
+		note.UpdatedAt = time.Now()
+		if err := h.noteRepo.Create(note); err != nil {
+			log.Printf("Sync note create error: %v", err)
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not create note"}
+		}
+		touched[note.ID] = &syncTouch{event: realtime.EventNoteCreated}
+		return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncNote(note)}
+	}
+	if err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: err.Error()}
+	}
+
+	required := models.NoteRoleEditor
+	if m.Op == "delete" {
+		required = models.NoteRoleOwner
+	}
+	if status := h.checkRole(note, noteUserID(user), required); status != "" {
+		return models.SyncMutationResult{Status: status}
+	}
+	if isSyncConflict(note.UpdatedAt, m.BaseUpdatedAt) {
+		return models.SyncMutationResult{Status: syncStatusConflict, Server: toSyncNote(note)}
+	}
+
+	// The writes only go through if no one changed the note since it was loaded above
+	if m.Op == "delete" {
+		if err := h.noteRepo.DeleteIfVersion(note.ID, note.Version); err != nil {
+			if errors.Is(err, repositories.ErrVersionConflict) {
+				return h.noteConflict(note.ID)
+			}
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not delete note"}
+		}
+		touched[note.ID] = &syncTouch{event: realtime.EventNoteDeleted}
+		return models.SyncMutationResult{Status: syncStatusApplied}
+	}
+
+	applyNoteData(note, m.Data)
+	note.UpdatedBy = noteUserID(user)
+	note.UpdatedAt = time.Now()
+	if err := h.noteRepo.UpdateIfVersion(note, note.Version); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			return h.noteConflict(note.ID)
+		}
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not update note"}
+	}
+	if _, seen := touched[note.ID]; !seen {
+		touched[note.ID] = &syncTouch{event: realtime.EventNoteUpdated}
+	}
+	return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncNote(note)}
+}
+
+// noteConflict reports a conflict with the note as it is now, after a concurrent write won
+func (h *SyncHandler) noteConflict(noteID uuid.UUID) models.SyncMutationResult {
+	current, err := h.noteRepo.GetByID(noteID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		current, err = h.noteRepo.GetTrashedByID(noteID)
+	}
+	if err != nil {
+		return models.SyncMutationResult{Status: syncStatusConflict}
+	}
+	return models.SyncMutationResult{Status: syncStatusConflict, Server: toSyncNote(current)}
+}
+
+func (h *SyncHandler) applyChecklistItem(user *models.User, m models.SyncMutation, touched map[uuid.UUID]*syncTouch) models.SyncMutationResult {
+	item, err := h.noteRepo.GetChecklistItemByID(m.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if m.Op == "delete" {
+			return models.SyncMutationResult{Status: syncStatusNotFound}
+		}
+		// The ID of a deleted item can't be reused; the client gets the tombstone to drop its copy
+		if trashed, _ := h.noteRepo.GetTrashedChecklistItemByID(m.ID); trashed != nil {
+			if status := h.checkNoteRole(trashed.NoteID, noteUserID(user)); status != "" {
+				return models.SyncMutationResult{Status: status}
+			}
+			return models.SyncMutationResult{Status: syncStatusConflict, Error: "checklist item was deleted", Server: toSyncChecklistItem(trashed)}
+		}
+		if m.Data.NoteID == nil {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "note_id is required"}
+		}
+		if status := h.checkNoteRole(*m.Data.NoteID, noteUserID(user)); status != "" {
+			return models.SyncMutationResult{Status: status}
+		}
+
+		item = &models.ChecklistItem{ID: m.ID, NoteID: *m.Data.NoteID}
+		applyChecklistItemData(item, m.Data)
+		if err := h.noteRepo.CreateChecklistItem(item); err != nil {
+			log.Printf("Sync checklist item create error: %v", err)
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not create checklist item"}
+		}
+		markNoteUpdated(touched, item.NoteID)
+		return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncChecklistItem(item)}
+	}
+	if err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: err.Error()}
+	}
+
+	if status := h.checkNoteRole(item.NoteID, noteUserID(user)); status != "" {
+		return models.SyncMutationResult{Status: status}
+	}
+	if isSyncConflict(item.UpdatedAt, m.BaseUpdatedAt) {
+		return models.SyncMutationResult{Status: syncStatusConflict, Server: toSyncChecklistItem(item)}
+	}
+
+	if m.Op == "delete" {
+		if err := h.noteRepo.DeleteChecklistItem(item.ID); err != nil {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not delete checklist item"}
+		}
+		markNoteUpdated(touched, item.NoteID)
+		return models.SyncMutationResult{Status: syncStatusApplied}
+	}
+
+	wasChecked := item.IsChecked
+	applyChecklistItemData(item, m.Data)
+	if err := h.noteRepo.UpdateChecklistItem(item); err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not update checklist item"}
+	}
+	if item.IsChecked != wasChecked {
+		if err := h.noteRepo.SettleChecklist(item.NoteID, []uuid.UUID{item.ID}); err != nil {
+			log.Printf("Sync checklist item settle error: %v", err)
+		} else if settled, err := h.noteRepo.GetChecklistItemByID(item.ID); err == nil {
+			item = settled
+		}
+	}
+	markNoteUpdated(touched, item.NoteID)
+	return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncChecklistItem(item)}
+}
+
+func (h *SyncHandler) applyReminder(user *models.User, m models.SyncMutation, touched map[uuid.UUID]*syncTouch) models.SyncMutationResult {
+	reminder, err := h.noteRepo.GetReminderByID(m.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if m.Op == "delete" {
+			return models.SyncMutationResult{Status: syncStatusNotFound}
+		}
+		if trashed, _ := h.noteRepo.GetTrashedReminderByID(m.ID); trashed != nil {
+			if status := h.checkNoteRole(trashed.NoteID, noteUserID(user)); status != "" {
+				return models.SyncMutationResult{Status: status}
+			}
+			return models.SyncMutationResult{Status: syncStatusConflict, Error: "reminder was deleted", Server: toSyncReminder(trashed)}
+		}
+		if m.Data.NoteID == nil || m.Data.Time == nil {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "note_id and time are required"}
+		}
+		if status := h.checkNoteRole(*m.Data.NoteID, noteUserID(user)); status != "" {
+			return models.SyncMutationResult{Status: status}
+		}
+
+		reminder = &models.Reminder{ID: m.ID, NoteID: *m.Data.NoteID, Time: *m.Data.Time}
+		applyReminderSchedule(reminder, m.Data)
+		if reminder.Timezone == "" {
+			reminder.Timezone = user.Preferences.Timezone
+		}
+		if err := repositories.ValidateReminder(reminder); err != nil {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: err.Error()}
+		}
+		if err := h.noteRepo.CreateReminder(reminder); err != nil {
+			log.Printf("Sync reminder create error: %v", err)
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not create reminder"}
+		}
+		markNoteUpdated(touched, reminder.NoteID)
+		return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncReminder(reminder)}
+	}
+	if err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: err.Error()}
+	}
+
+	if status := h.checkNoteRole(reminder.NoteID, noteUserID(user)); status != "" {
+		return models.SyncMutationResult{Status: status}
+	}
+	if isSyncConflict(reminder.UpdatedAt, m.BaseUpdatedAt) {
+		return models.SyncMutationResult{Status: syncStatusConflict, Server: toSyncReminder(reminder)}
+	}
+
+	if m.Op == "delete" {
+		if err := h.noteRepo.DeleteReminder(reminder.ID); err != nil {
+			return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not delete reminder"}
+		}
+		markNoteUpdated(touched, reminder.NoteID)
+		return models.SyncMutationResult{Status: syncStatusApplied}
+	}
+
+	if m.Data.Time != nil {
+		reminder.Time = *m.Data.Time
+	}
+	applyReminderSchedule(reminder, m.Data)
+	if err := repositories.ValidateReminder(reminder); err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: err.Error()}
+	}
+	if err := h.noteRepo.UpdateReminder(reminder); err != nil {
+		return models.SyncMutationResult{Status: syncStatusInvalid, Error: "could not update reminder"}
+	}
+	markNoteUpdated(touched, reminder.NoteID)
+	return models.SyncMutationResult{Status: syncStatusApplied, Server: toSyncReminder(reminder)}
+}
+
+// applyReminderSchedule copies the recurrence fields a mutation supplies onto a reminder
+func applyReminderSchedule(reminder *models.Reminder, data models.SyncMutationData) {
+	if data.RRule != nil {
+		reminder.RRule = *data.RRule
+	}
+	if data.Timezone != nil {
+		reminder.Timezone = *data.Timezone
+	}
+}
+
+// checkNoteRole loads a live note and checks the user may edit it, returning a sync status on failure
+func (h *SyncHandler) checkNoteRole(noteID uuid.UUID, userID string) string {
+	note, err := h.noteRepo.GetByID(noteID)
+	if err != nil {
+		return syncStatusNotFound
+	}
+	return h.checkRole(note, userID, models.NoteRoleEditor)
+}
+
+// checkRole returns a sync status if the user's role on the note is below required, or "" if allowed
+func (h *SyncHandler) checkRole(note *models.Note, userID, required string) string {
+	role, err := h.collabRepo.RoleFor(note, userID)
+	if err != nil {
+		return syncStatusInvalid
+	}
+	if role == "" {
+		return syncStatusNotFound
+	}
+	if noteRoleRank[role] < noteRoleRank[required] {
+		return syncStatusForbidden
+	}
+	return ""
+}
+
+// isSyncConflict reports whether the server copy changed after the version the client based its edit on
+func isSyncConflict(serverUpdatedAt time.Time, base *time.Time) bool {
+	if base == nil {
+		return true
+	}
+	return serverUpdatedAt.Truncate(time.Microsecond).After(base.Truncate(time.Microsecond))
+}
+
+// syncTouch is what a push did to a note, announced once the whole batch has been applied
+type syncTouch struct {
+	event    string
+	children bool // its checklist items or reminders changed
+}
+
+// markNoteUpdated notes that one of a note's checklist items or reminders changed
+func markNoteUpdated(touched map[uuid.UUID]*syncTouch, noteID uuid.UUID) {
+	touch, seen := touched[noteID]
+	if !seen {
+		touch = &syncTouch{event: realtime.EventNoteUpdated}
+		touched[noteID] = touch
+	}
+	touch.children = true
+}
+
+func applyNoteData(note *models.Note, data models.SyncMutationData) {
+	if data.Title != nil {
+		note.Title = *data.Title
+	}
+	if data.Description != nil {
+		note.Description = *data.Description
+	}
+	if data.IsPinned != nil {
+		note.IsPinned = *data.IsPinned
+	}
+	if data.IsArchived != nil {
+		note.IsArchived = *data.IsArchived
+	}
+	if data.IsChecklist != nil {
+		note.IsChecklist = *data.IsChecklist
+	}
+}
+
+func applyChecklistItemData(item *models.ChecklistItem, data models.SyncMutationData) {
+	if data.Text != nil {
+		item.Text = *data.Text
+	}
+	if data.IsChecked != nil {
+		item.IsChecked = *data.IsChecked
+	}
+}
+
+func encodeSyncToken(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + t.UTC().Format(time.RFC3339Nano)))
+}
+
+func decodeSyncToken(token string) (time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, ok := strings.CutPrefix(string(raw), "v1:")
+	if !ok {
+		return time.Time{}, errors.New("unsupported sync token version")
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func deletedAtPtr(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Time
+}
+
+func toSyncNote(note *models.Note) models.SyncNote {
+	return models.SyncNote{
+		ID:          note.ID,
+		Title:       note.Title,
+		Description: note.Description,
+		IsPinned:    note.IsPinned,
+		IsArchived:  note.IsArchived,
+		IsChecklist: note.IsChecklist,
+		Version:     note.Version,
+		Rank:        note.Rank,
+		CreatedBy:   note.CreatedBy,
+		UpdatedBy:   note.UpdatedBy,
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+		DeletedAt:   deletedAtPtr(note.DeletedAt),
+	}
+}
+
+func toSyncChecklistItem(item *models.ChecklistItem) models.SyncChecklistItem {
+	return models.SyncChecklistItem{
+		ID:        item.ID,
+		NoteID:    item.NoteID,
+		ParentID:  item.ParentID,
+		Text:      item.Text,
+		IsChecked: item.IsChecked,
+		Rank:      item.Rank,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+		DeletedAt: deletedAtPtr(item.DeletedAt),
+	}
+}
+
+func toSyncReminder(reminder *models.Reminder) models.SyncReminder {
+	return models.SyncReminder{
+		ID:        reminder.ID,
+		NoteID:    reminder.NoteID,
+		Time:      reminder.Time,
+		RRule:     reminder.RRule,
+		Timezone:  reminder.Timezone,
+		Status:    reminder.Status,
+		CreatedAt: reminder.CreatedAt,
+		UpdatedAt: reminder.UpdatedAt,
+		DeletedAt: deletedAtPtr(reminder.DeletedAt),
+	}
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 
@@ -24,7 +24,15 @@ func NewUserHandler(db *gorm.DB) *UserHandler {
 	}
 }
 
+// CreateUser adds a user. Regular users may only create the row for their own Clerk account;
+// admins may create any user, including email/password ones.
 func (h *UserHandler) CreateUser(c *gin.Context) {
+	caller, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("JSON bind error:", err)
@@ -32,14 +40,30 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if caller.Role != models.UserRoleAdmin {
+		if caller.ClerkID == "" || req.Password != "" || (req.ClerkID != "" && req.ClerkID != caller.ClerkID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		req.ClerkID = caller.ClerkID
+	}
+
 	if req.ClerkID == "" && req.Password == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is required for manual sign-up"})
 		return
 	}
 
 	// Check if user already exists
-	existingUser, err := h.repo.FindByClerkID(req.ClerkID)
-	if err != nil && err != sql.ErrNoRows {
+	var existingUser *models.User
+	if req.ClerkID != "" {
+		existingUser, err = h.repo.FindByClerkID(req.ClerkID)
+	} else {
+		existingUser, err = h.repo.GetByEmail(req.Email)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existingUser, err = nil, nil
+		}
+	}
+	if err != nil {
 		log.Println("Error checking existing user:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user existence"})
 		return
@@ -61,27 +85,29 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	}
 
 	user := &models.User{
-		ID:        uuid.New(),
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Email:     req.Email,
-		ClerkID:   req.ClerkID,
-		ImageURL:  req.ImageUrl,
-		Password:  hashedPassword,
+		ID:          uuid.New(),
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		ClerkID:     req.ClerkID,
+		ImageURL:    req.ImageUrl,
+		Password:    hashedPassword,
+		Role:        models.UserRoleUser,
+		Preferences: models.DefaultUserPreferences(),
 	}
 
 	if err := h.repo.Create(user); err != nil {
 		log.Println("Database create error:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": user.ID})
+	c.JSON(http.StatusCreated, toUserResponse(user))
 }
 
+// GetUser returns any user; admin only. Users read their own profile with GetMe.
 func (h *UserHandler) GetUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
@@ -93,32 +119,32 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, toUserResponse(user))
 }
 
+// UpdateUser changes any user's profile or role; admin only. Users edit their own profile with UpdateMe.
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user.ID = id
-	if err := h.repo.Update(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	user, err := h.repo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
-
-	c.JSON(http.StatusOK, user)
+	h.applyUpdate(c, user, &req)
 }
 
+// DeleteUser removes a user; admin only
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
@@ -127,7 +153,12 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	err = h.repo.Delete(id)
+	if errors.Is(err, repositories.ErrLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -135,6 +166,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// ListUsers returns every user; admin only
 func (h *UserHandler) ListUsers(c *gin.Context) {
 	users, err := h.repo.List()
 	if err != nil {
@@ -142,5 +174,95 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	resp := make([]models.UserResponse, len(users))
+	for i := range users {
+		resp[i] = toUserResponse(&users[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetMe returns the caller's own profile
+func (h *UserHandler) GetMe(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// UpdateMe changes the caller's own profile. Roles can't be changed here.
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change roles"})
+		return
+	}
+
+	// Clerk users only get a row once they have something to store
+	if user.ID == uuid.Nil {
+		if err := h.repo.EnsureClerkUser(user); err != nil {
+			log.Printf("Failed to load user: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+	}
+	h.applyUpdate(c, user, &req)
+}
+
+// applyUpdate saves just the fields present in req to user's row and responds with the result
+func (h *UserHandler) applyUpdate(c *gin.Context, user *models.User, req *models.UpdateUserRequest) {
+	updates := map[string]interface{}{}
+	if req.FirstName != nil {
+		updates["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		updates["last_name"] = *req.LastName
+	}
+	if req.ImageURL != nil {
+		updates["image_url"] = *req.ImageURL
+	}
+	if req.Role != nil {
+		updates["role"] = *req.Role
+	}
+
+	if len(updates) > 0 {
+		err := h.repo.UpdateProfile(user, updates)
+		if errors.Is(err, repositories.ErrLastAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("Database update error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func toUserResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:        user.ID,
+		ClerkID:   user.ClerkID,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		ImageURL:  user.ImageURL,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
 }
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"todo-backend/config"
+	"todo-backend/models"
+	"todo-backend/repositories"
+	"todo-backend/svix"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxWebhookBody bounds the webhook payloads we read
+const maxWebhookBody = 1 << 20
+
+type WebhookHandler struct {
+	eventRepo     *repositories.WebhookEventRepository
+	clerkVerifier *svix.Verifier
+	adminEmails   map[string]bool
+}
+
+func NewWebhookHandler(db *gorm.DB) *WebhookHandler {
+	h := &WebhookHandler{
+		eventRepo:   repositories.NewWebhookEventRepository(db),
+		adminEmails: make(map[string]bool),
+	}
+	for _, email := range config.AdminEmails() {
+		h.adminEmails[email] = true
+	}
+	if secret := config.Clerk().WebhookSecret; secret != "" {
+		verifier, err := svix.NewVerifier(secret)
+		if err != nil {
+			log.Printf("⚠️ Invalid CLERK_WEBHOOK_SECRET, Clerk webhooks are disabled: %v", err)
+		}
+		h.clerkVerifier = verifier
+	}
+	return h
+}
+
+// ClerkEvent keeps users in step with Clerk: user.created and user.updated upsert the user's
+// row by Clerk ID and user.deleted soft-deletes it. Each Svix message is applied once; Svix
+// retries anything but a 2xx response. Events may arrive out of order, so a profile older than
+// the stored one is ignored, and a deletion that comes first leaves a deleted row behind that
+// the user's creation can't revive. A user whose verified primary email is in ADMIN_EMAILS
+// becomes an admin.
+func (h *WebhookHandler) ClerkEvent(c *gin.Context) {
+	if h.clerkVerifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Clerk webhooks are not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Payload too large"})
+		return
+	}
+	if err := h.clerkVerifier.Verify(c.Request.Header, body, time.Now()); err != nil {
+		log.Printf("Clerk webhook rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	var event models.ClerkWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+	var data models.ClerkUserData
+	switch event.Type {
+	case "user.created", "user.updated", "user.deleted":
+		if err := json.Unmarshal(event.Data, &data); err != nil || data.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user payload"})
+			return
+		}
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	apply := func(tx *gorm.DB) error {
+		users := repositories.NewUserRepository(tx)
+		if event.Type == "user.deleted" {
+			return users.DeleteByClerkID(data.ID)
+		}
+		user := clerkWebhookUser(&data, h.adminEmails)
+		return users.UpsertClerkUser(&user)
+	}
+	record := models.WebhookEvent{ID: c.GetHeader("svix-id"), Source: "clerk", Type: event.Type}
+	applied, err := h.eventRepo.Process(&record, apply)
+	if err != nil {
+		log.Printf("Clerk webhook %s error: %v", event.Type, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process event"})
+		return
+	}
+	if !applied {
+		c.JSON(http.StatusOK, gin.H{"message": "Event already processed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+// clerkWebhookUser copies a Clerk user's profile into a models.User, with the admin role if
+// their primary email is verified and one of adminEmails
+func clerkWebhookUser(data *models.ClerkUserData, adminEmails map[string]bool) models.User {
+	updatedAt := time.Now()
+	if data.UpdatedAt > 0 {
+		updatedAt = time.UnixMilli(data.UpdatedAt)
+	}
+	user := models.User{
+		ClerkID:        data.ID,
+		ImageURL:       data.ImageURL,
+		ClerkUpdatedAt: &updatedAt,
+		Role:           models.UserRoleUser,
+		Preferences:    models.DefaultUserPreferences(),
+	}
+	if data.FirstName != nil {
+		user.FirstName = *data.FirstName
+	}
+	if data.LastName != nil {
+		user.LastName = *data.LastName
+	}
+	for _, email := range data.EmailAddresses {
+		if data.PrimaryEmailAddressID != nil && email.ID == *data.PrimaryEmailAddressID {
+			user.Email = email.EmailAddress
+			verified := email.Verification != nil && email.Verification.Status == "verified"
+			user.EmailVerified = verified
+			if verified && adminEmails[strings.ToLower(email.EmailAddress)] {
+				user.Role = models.UserRoleAdmin
+			}
+		}
+	}
+	return user
+}
@@ -2,6 +2,12 @@ package routes
 
 import (
 	"todo-backend/api/handlers"
+	"todo-backend/auth"
+	"todo-backend/mail"
+	"todo-backend/middleware"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
 
 	"time"
 
@@ -10,7 +16,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(db *gorm.DB) *gin.Engine {
+func SetupRoutes(db *gorm.DB, hub realtime.Broker, mailer mail.Sender, authenticator auth.Authenticator) *gin.Engine {
 	r := gin.Default()
 	r.SetTrustedProxies(nil)
 
@@ -18,39 +24,169 @@ func SetupRoutes(db *gorm.DB) *gin.Engine {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "https://todo-nomadule.netlify.app"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Share-Password", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// requireAuth resolves the caller to a user for handlers; routes without it are public
+	requireAuth := middleware.AuthMiddleware(authenticator)
+
 	// User routes
 	userHandler := handlers.NewUserHandler(db)
-	userGroup := r.Group("/users")
+	requireAdmin := middleware.RequireRole(models.UserRoleAdmin)
+	userGroup := r.Group("/users", requireAuth)
 	{
 		userGroup.POST("", userHandler.CreateUser)
-		userGroup.GET("", userHandler.ListUsers)
-		userGroup.GET("/:id", userHandler.GetUser)
-		userGroup.PUT("/:id", userHandler.UpdateUser)
-		userGroup.DELETE("/:id", userHandler.DeleteUser)
+		userGroup.GET("", requireAdmin, userHandler.ListUsers)
+		userGroup.GET("/:id", requireAdmin, userHandler.GetUser)
+		userGroup.PUT("/:id", requireAdmin, userHandler.UpdateUser)
+		userGroup.DELETE("/:id", requireAdmin, userHandler.DeleteUser)
+	}
+
+	// Current user routes
+	preferencesHandler := handlers.NewPreferencesHandler(db)
+	meGroup := r.Group("/me", requireAuth)
+	{
+		meGroup.GET("", userHandler.GetMe)
+		meGroup.PATCH("", userHandler.UpdateMe)
+		meGroup.GET("/preferences", preferencesHandler.GetPreferences)
+		meGroup.PUT("/preferences", preferencesHandler.UpdatePreferences)
 	}
 
 	// Note routes
-	noteHandler := handlers.NewNoteHandler(db)
-	noteGroup := r.Group("/notes")
+	noteHandler := handlers.NewNoteHandler(db, hub)
+	noteGroup := r.Group("/notes", requireAuth)
 	{
 		noteGroup.POST("", noteHandler.CreateNote)
+		noteGroup.POST("/quick", noteHandler.QuickAddNote)
 		noteGroup.GET("", noteHandler.GetAllNotes)
+		noteGroup.GET("/search", noteHandler.SearchNotes)
+		noteGroup.GET("/trash", noteHandler.GetTrash)
+		noteGroup.POST("/stream/ticket", noteHandler.CreateStreamTicket)
 		noteGroup.GET("/:id", noteHandler.GetNoteByID)
 		noteGroup.PUT("/:id", noteHandler.UpdateNote)
+		noteGroup.PATCH("/:id", noteHandler.PatchNote)
 		noteGroup.DELETE("/:id", noteHandler.DeleteNote)
+		noteGroup.POST("/:id/move", noteHandler.MoveNote)
+		noteGroup.POST("/:id/restore", noteHandler.RestoreNote)
+		noteGroup.DELETE("/:id/purge", noteHandler.PurgeNote)
+	}
+	// EventSource can't send headers, so the stream also accepts a single-use ?ticket=
+	streamTickets := auth.NewStreamTicketAuthenticator(repositories.NewStreamTicketRepository(db), repositories.NewUserRepository(db))
+	r.GET("/notes/stream", middleware.StreamTicket(streamTickets), requireAuth, noteHandler.StreamNotes)
+
+	// Checklist item routes
+	checklistItemHandler := handlers.NewChecklistItemHandler(db, hub)
+	itemGroup := noteGroup.Group("/:id/items")
+	{
+		itemGroup.POST("", checklistItemHandler.CreateItem)
+		itemGroup.PUT("/order", checklistItemHandler.ReorderItems)
+		itemGroup.PATCH("/:itemId", checklistItemHandler.UpdateItem)
+		itemGroup.DELETE("/:itemId", checklistItemHandler.DeleteItem)
+		itemGroup.POST("/:itemId/toggle", checklistItemHandler.ToggleItem)
+		itemGroup.POST("/:itemId/move", checklistItemHandler.MoveItem)
 	}
 
-	// // Auth routes
-	// authHandler := handlers.NewAuthHandler(db)
-	// authGroup := r.Group("/auth")
-	// {
-	// 	authGroup.POST("/login", authHandler.Login)
-	// }
+	// Reminder routes
+	reminderHandler := handlers.NewReminderHandler(db, hub)
+	reminderGroup := noteGroup.Group("/:id/reminders")
+	{
+		reminderGroup.GET("/:reminderId", reminderHandler.GetReminder)
+		reminderGroup.POST("/:reminderId/skip", reminderHandler.SkipOccurrence)
+		reminderGroup.POST("/:reminderId/reschedule", reminderHandler.RescheduleOccurrence)
+	}
+	remindersGroup := r.Group("/reminders", requireAuth)
+	{
+		remindersGroup.GET("/upcoming", reminderHandler.ListUpcoming)
+		remindersGroup.POST("/:id/snooze", reminderHandler.Snooze)
+		remindersGroup.POST("/:id/dismiss", reminderHandler.Dismiss)
+	}
+
+	// Notification routes
+	notificationHandler := handlers.NewNotificationHandler(db)
+	notificationGroup := r.Group("/notifications", requireAuth)
+	{
+		notificationGroup.GET("", notificationHandler.ListNotifications)
+		notificationGroup.POST("/:id/read", notificationHandler.MarkRead)
+	}
+
+	// Revision routes
+	revisionHandler := handlers.NewRevisionHandler(db, hub)
+	revisionGroup := noteGroup.Group("/:id/revisions")
+	{
+		revisionGroup.GET("", revisionHandler.ListRevisions)
+		revisionGroup.GET("/diff", revisionHandler.DiffRevisions)
+		revisionGroup.GET("/:rev", revisionHandler.GetRevision)
+		revisionGroup.POST("/:rev/restore", revisionHandler.RestoreRevision)
+	}
+
+	// Collaborator routes
+	collaboratorHandler := handlers.NewCollaboratorHandler(db)
+	collaboratorGroup := noteGroup.Group("/:id/collaborators")
+	{
+		collaboratorGroup.POST("", collaboratorHandler.InviteCollaborator)
+		collaboratorGroup.GET("", collaboratorHandler.ListCollaborators)
+		collaboratorGroup.PATCH("/:userId", collaboratorHandler.UpdateCollaborator)
+		collaboratorGroup.DELETE("/:userId", collaboratorHandler.RevokeCollaborator)
+	}
+
+	// Share link routes
+	shareLinkHandler := handlers.NewShareLinkHandler(db)
+	shareLinkGroup := noteGroup.Group("/:id/share-link")
+	{
+		shareLinkGroup.POST("", shareLinkHandler.CreateShareLink)
+		shareLinkGroup.GET("", shareLinkHandler.ListShareLinks)
+		shareLinkGroup.DELETE("/:linkId", shareLinkHandler.RevokeShareLink)
+	}
+
+	// Webhooks (no authentication; each source signs its payloads)
+	webhookHandler := handlers.NewWebhookHandler(db)
+	r.POST("/webhooks/clerk", webhookHandler.ClerkEvent)
+
+	// Public shared note routes (no authentication)
+	r.GET("/shared/:token", shareLinkHandler.GetSharedNote)
+
+	// Calendar feed routes. GET /calendar/<token>.ics is public: the token in the URL is the credential.
+	calendarHandler := handlers.NewCalendarHandler(db)
+	calendarGroup := r.Group("/calendar")
+	{
+		calendarGroup.GET("/feed", requireAuth, calendarHandler.GetFeed)
+		calendarGroup.POST("/feed", requireAuth, calendarHandler.RotateFeed)
+		calendarGroup.DELETE("/feed", requireAuth, calendarHandler.DeleteFeed)
+		calendarGroup.GET("/:file", calendarHandler.GetCalendar)
+	}
+
+	// Sync routes
+	syncHandler := handlers.NewSyncHandler(db, hub)
+	syncGroup := r.Group("/sync", requireAuth)
+	{
+		syncGroup.GET("", syncHandler.Pull)
+		syncGroup.POST("", syncHandler.Push)
+	}
+
+	// Label routes
+	labelHandler := handlers.NewLabelHandler(db)
+	labelGroup := r.Group("/labels", requireAuth)
+	{
+		labelGroup.POST("", labelHandler.CreateLabel)
+		labelGroup.GET("", labelHandler.ListLabels)
+		labelGroup.GET("/:id", labelHandler.GetLabel)
+		labelGroup.PUT("/:id", labelHandler.UpdateLabel)
+		labelGroup.DELETE("/:id", labelHandler.DeleteLabel)
+	}
+
+	// Auth routes (email and password accounts)
+	authHandler := handlers.NewAuthHandler(db, mailer)
+	authGroup := r.Group("/auth")
+	{
+		authGroup.POST("/register", authHandler.Register)
+		authGroup.POST("/login", authHandler.Login)
+		authGroup.POST("/refresh", authHandler.Refresh)
+		authGroup.POST("/logout", authHandler.Logout)
+		authGroup.POST("/forgot-password", authHandler.ForgotPassword)
+		authGroup.POST("/reset-password", authHandler.ResetPassword)
+	}
 	return r
 }
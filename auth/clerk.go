@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+	"todo-backend/models"
+	"todo-backend/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksTTL is how long fetched signing keys are used before they are fetched again
+	jwksTTL = time.Hour
+	// jwksMinRefresh limits refetches for tokens signed with a key we don't know
+	jwksMinRefresh = time.Minute
+	// jwksRetryDelay is the wait after a failed fetch; it doubles with each further failure up to jwksMinRefresh
+	jwksRetryDelay = time.Second
+	// clerkLeeway tolerates clock skew; Clerk session tokens only live for a minute
+	clerkLeeway = 5 * time.Second
+)
+
+// ClerkAuthenticator verifies Clerk session tokens offline against Clerk's published signing
+// keys. A Clerk user we have no row for yet is still let in, without a row (see unsavedClerkUser).
+type ClerkAuthenticator struct {
+	Keys *JWKS
+	// Issuer and AuthorizedParties, when set, must match the token's iss and azp claims
+	Issuer            string
+	AuthorizedParties []string
+	Users             *repositories.UserRepository
+}
+
+func NewClerkAuthenticator(keys *JWKS, issuer string, authorizedParties []string, users *repositories.UserRepository) *ClerkAuthenticator {
+	return &ClerkAuthenticator{Keys: keys, Issuer: issuer, AuthorizedParties: authorizedParties, Users: users}
+}
+
+type clerkClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty string `json:"azp"`
+}
+
+func (a *ClerkAuthenticator) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	var keyErr error
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := a.Keys.Key(ctx, kid)
+		if err != nil && !errors.Is(err, errUnknownKey) {
+			keyErr = err
+		}
+		return key, err
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods([]string{"RS256"}), jwt.WithExpirationRequired(), jwt.WithLeeway(clerkLeeway)}
+	if a.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.Issuer))
+	}
+	var claims clerkClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, keyFunc, opts...); err != nil {
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if len(a.AuthorizedParties) > 0 && claims.AuthorizedParty != "" && !slices.Contains(a.AuthorizedParties, claims.AuthorizedParty) {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidToken, claims.AuthorizedParty)
+	}
+
+	user, err := a.Users.FindByClerkID(claims.Subject)
+	if err != nil || user != nil {
+		return user, err
+	}
+	return unsavedClerkUser(claims.Subject), nil
+}
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// JWKS fetches and caches the RSA public keys of a JSON Web Key Set, refetching them after
+// jwksTTL or when a token names a key it doesn't have. Only one fetch runs at a time and
+// requests needing it wait for it without holding the lock; failed fetches back off.
+type JWKS struct {
+	URL string
+	// Header is sent with the request, e.g. the Authorization Clerk's backend API wants
+	Header http.Header
+	Client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time     // last successful fetch
+	attemptedAt time.Time     // last fetch, successful or not
+	failures    int           // failed fetches since the last successful one
+	lastErr     error         // why the last fetch failed, if it did
+	fetching    chan struct{} // closed when the running fetch finishes; nil if none is running
+}
+
+func NewJWKS(url string, header http.Header) *JWKS {
+	return &JWKS{URL: url, Header: header, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Key returns the key with the given ID
+func (k *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	for {
+		k.mu.Lock()
+		key, ok := k.keys[kid]
+		if ok && time.Since(k.fetchedAt) <= jwksTTL {
+			k.mu.Unlock()
+			return key, nil
+		}
+		if time.Since(k.attemptedAt) < k.retryDelay() {
+			err := k.lastErr
+			k.mu.Unlock()
+			// Keep using the keys we have while the key server is unreachable
+			if ok {
+				return key, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return nil, errUnknownKey
+		}
+
+		if k.fetching == nil {
+			k.fetching = make(chan struct{})
+			go k.refresh(k.fetching)
+		}
+		fetching := k.fetching
+		k.mu.Unlock()
+
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryDelay is how long after the last fetch another may start. k.mu must be held.
+func (k *JWKS) retryDelay() time.Duration {
+	if k.failures == 0 {
+		return jwksMinRefresh
+	}
+	delay := jwksRetryDelay
+	for i := 1; i < k.failures && delay < jwksMinRefresh; i++ {
+		delay *= 2
+	}
+	return min(delay, jwksMinRefresh)
+}
+
+// refresh fetches the keys and closes done. It isn't tied to any one request, so a caller
+// giving up doesn't fail the fetch for the others waiting on it.
+func (k *JWKS) refresh(done chan struct{}) {
+	keys, err := k.fetch(context.Background())
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.attemptedAt = time.Now()
+	if err != nil {
+		k.failures++
+		k.lastErr = err
+	} else {
+		k.keys, k.fetchedAt = keys, k.attemptedAt
+		k.failures, k.lastErr = 0, nil
+	}
+	k.fetching = nil
+	close(done)
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+func (k *JWKS) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range k.Header {
+		req.Header[name] = values
+	}
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding signing keys: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := rsaPublicKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", jwk.KeyID, err)
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+func rsaPublicKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const defaultAppURL = "http://localhost:3000"
+
+// AppURL returns the base URL of the web app, used to link back to notes from outside it
+// (e.g. calendar entries), read from APP_URL
+func AppURL() string {
+	if v := os.Getenv("APP_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return defaultAppURL
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAccessTokenTTLMinutes = 15
+	defaultRefreshTokenTTLDays   = 30
+	defaultPasswordResetMinutes  = 30
+	defaultAuthProviders         = "clerk,local"
+	defaultClerkJWKSURL          = "https://api.clerk.com/v1/jwks"
+)
+
+// AuthProviders returns the ways API requests may authenticate, read from AUTH_PROVIDERS as a
+// comma-separated list of clerk, local and test. Clerk and local are enabled by default.
+func AuthProviders() []string {
+	v := os.Getenv("AUTH_PROVIDERS")
+	if v == "" {
+		v = defaultAuthProviders
+	}
+	var providers []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// AdminEmails returns the lower-cased emails in ADMIN_EMAILS (comma separated). A Clerk user
+// whose primary email is verified and listed here is made an admin when Clerk syncs them.
+func AdminEmails() []string {
+	var emails []string
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// ClerkSettings configures verification of Clerk session tokens
+type ClerkSettings struct {
+	SecretKey         string
+	JWKSURL           string
+	Issuer            string
+	AuthorizedParties []string
+	WebhookSecret     string
+}
+
+// Clerk reads CLERK_SECRET_KEY, CLERK_JWKS_URL (Clerk's backend API by default, which needs the
+// secret key), and the optional CLERK_ISSUER and comma-separated CLERK_AUTHORIZED_PARTIES that
+// tokens must match. CLERK_WEBHOOK_SECRET is the signing secret ("whsec_...") of the webhook
+// endpoint; Clerk webhooks are refused while it is unset.
+func Clerk() ClerkSettings {
+	settings := ClerkSettings{
+		SecretKey:     os.Getenv("CLERK_SECRET_KEY"),
+		JWKSURL:       os.Getenv("CLERK_JWKS_URL"),
+		Issuer:        os.Getenv("CLERK_ISSUER"),
+		WebhookSecret: os.Getenv("CLERK_WEBHOOK_SECRET"),
+	}
+	if settings.JWKSURL == "" {
+		settings.JWKSURL = defaultClerkJWKSURL
+	}
+	for _, p := range strings.Split(os.Getenv("CLERK_AUTHORIZED_PARTIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			settings.AuthorizedParties = append(settings.AuthorizedParties, p)
+		}
+	}
+	return settings
+}
+
+// JWTSecret returns the key access tokens are signed with, read from JWT_SECRET_KEY.
+// Local sign-in is unavailable while it is unset.
+func JWTSecret() []byte {
+	return []byte(os.Getenv("JWT_SECRET_KEY"))
+}
+
+// AccessTokenTTL returns how long an access token is valid, read from ACCESS_TOKEN_TTL_MINUTES
+func AccessTokenTTL() time.Duration {
+	return time.Duration(positiveIntEnv("ACCESS_TOKEN_TTL_MINUTES", defaultAccessTokenTTLMinutes)) * time.Minute
+}
+
+// RefreshTokenTTL returns how long a session lasts without being refreshed, read from REFRESH_TOKEN_TTL_DAYS
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(positiveIntEnv("REFRESH_TOKEN_TTL_DAYS", defaultRefreshTokenTTLDays)) * 24 * time.Hour
+}
+
+// PasswordResetTTL returns how long a password reset link works, read from PASSWORD_RESET_TTL_MINUTES
+func PasswordResetTTL() time.Duration {
+	return time.Duration(positiveIntEnv("PASSWORD_RESET_TTL_MINUTES", defaultPasswordResetMinutes)) * time.Minute
+}
+
+func positiveIntEnv(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		log.Printf("⚠️ Invalid %s %q, using %d", name, v, fallback)
+		return fallback
+	}
+	return n
+}
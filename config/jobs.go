@@ -0,0 +1,25 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const defaultTrashRetentionDays = 30
+
+// TrashRetention returns how long deleted notes stay in the trash before they are purged,
+// read from TRASH_RETENTION_DAYS
+func TrashRetention() time.Duration {
+	days := defaultTrashRetentionDays
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Printf("⚠️ Invalid TRASH_RETENTION_DAYS %q, using %d", v, defaultTrashRetentionDays)
+		} else {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// SMTPSettings configures outgoing email (reminders and account emails)
+type SMTPSettings struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM.
+// Email is disabled (ok is false) when SMTP_HOST is unset.
+func SMTP() (settings SMTPSettings, ok bool) {
+	settings = SMTPSettings{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if settings.Port == "" {
+		settings.Port = "587"
+	}
+	if settings.From == "" {
+		settings.From = "reminders@localhost"
+	}
+	return settings, settings.Host != ""
+}
+
+// MailLogOnly reads MAIL_LOG_ONLY. When it is true and SMTP_HOST is unset, account emails are
+// written to the log instead, reset links included, which is only safe in local development.
+// Without either, password resets are disabled.
+func MailLogOnly() bool {
+	logOnly, _ := strconv.ParseBool(os.Getenv("MAIL_LOG_ONLY"))
+	return logOnly
+}
+
+// ReminderWebhook reads REMINDER_WEBHOOK_URL and the optional REMINDER_WEBHOOK_SECRET used to
+// sign payloads. Webhook reminders are disabled when the URL is unset.
+func ReminderWebhook() (url, secret string) {
+	return os.Getenv("REMINDER_WEBHOOK_URL"), os.Getenv("REMINDER_WEBHOOK_SECRET")
+}
@@ -0,0 +1,179 @@
+// Package ical writes iCalendar (RFC 5545) documents: a VCALENDAR of VEVENTs, each
+// optionally repeating and carrying a display alarm.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLineOctets is the longest a content line may be before it has to be folded
+const maxLineOctets = 75
+
+// Calendar is a VCALENDAR. Timezone, an IANA name, tells clients which zone to show it in.
+type Calendar struct {
+	ProdID   string
+	Name     string
+	Timezone string
+	Events   []Event
+}
+
+// Event is a VEVENT. Times are written in Location (with a TZID, so repeats follow its DST
+// rules, and a VTIMEZONE describing it) unless it is UTC. An event with RecurrenceID set replaces that one occurrence of the
+// repeating event sharing its UID.
+type Event struct {
+	UID          string
+	Stamp        time.Time
+	Start        time.Time
+	Location     *time.Location
+	Summary      string
+	Description  string
+	URL          string
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+	Alarm        bool
+}
+
+// Write renders the calendar with CRLF line endings and long lines folded
+func (cal *Calendar) Write(w io.Writer) error {
+	lw := &lineWriter{w: w}
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + cal.ProdID)
+	lw.line("CALSCALE:GREGORIAN")
+	lw.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		lw.line("X-WR-CALNAME:" + escapeText(cal.Name))
+	}
+	if cal.Timezone != "" {
+		lw.line("X-WR-TIMEZONE:" + cal.Timezone)
+	}
+	cal.writeTimezones(lw)
+	for i := range cal.Events {
+		cal.Events[i].write(lw)
+	}
+	lw.line("END:VCALENDAR")
+	return lw.err
+}
+
+// writeTimezones writes a VTIMEZONE for every zone a TZID names, covering the events' times
+// and a couple of years past now for the repeating ones
+func (cal *Calendar) writeTimezones(lw *lineWriter) {
+	type period struct{ from, until time.Time }
+	var locs []*time.Location
+	periods := map[string]*period{}
+	for i := range cal.Events {
+		e := &cal.Events[i]
+		if e.Location == nil || e.Location == time.UTC {
+			continue
+		}
+		p, ok := periods[e.Location.String()]
+		if !ok {
+			p = &period{from: e.Start, until: time.Now().AddDate(2, 0, 0)}
+			periods[e.Location.String()] = p
+			locs = append(locs, e.Location)
+		}
+		times := append([]time.Time{e.Start}, e.ExDates...)
+		if e.RecurrenceID != nil {
+			times = append(times, *e.RecurrenceID)
+		}
+		for _, t := range times {
+			if t.Before(p.from) {
+				p.from = t
+			}
+			if t.AddDate(1, 0, 0).After(p.until) {
+				p.until = t.AddDate(1, 0, 0)
+			}
+		}
+	}
+	for _, loc := range locs {
+		p := periods[loc.String()]
+		writeTimezone(lw, loc, p.from, p.until)
+	}
+}
+
+func (e *Event) write(lw *lineWriter) {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	lw.line("BEGIN:VEVENT")
+	lw.line("UID:" + escapeText(e.UID))
+	lw.line("DTSTAMP:" + formatUTC(e.Stamp))
+	lw.line("DTSTART" + formatTime(e.Start, loc))
+	if e.RecurrenceID != nil {
+		lw.line("RECURRENCE-ID" + formatTime(*e.RecurrenceID, loc))
+	}
+	if e.RRule != "" {
+		lw.line("RRULE:" + strings.TrimPrefix(e.RRule, "RRULE:"))
+	}
+	for _, t := range e.ExDates {
+		lw.line("EXDATE" + formatTime(t, loc))
+	}
+	lw.line("SUMMARY:" + escapeText(e.Summary))
+	if e.Description != "" {
+		lw.line("DESCRIPTION:" + escapeText(e.Description))
+	}
+	if e.URL != "" {
+		lw.line("URL:" + e.URL)
+	}
+	if e.Alarm {
+		lw.line("BEGIN:VALARM")
+		lw.line("ACTION:DISPLAY")
+		lw.line("DESCRIPTION:" + escapeText(e.Summary))
+		lw.line("TRIGGER:PT0S")
+		lw.line("END:VALARM")
+	}
+	lw.line("END:VEVENT")
+}
+
+// formatTime renders a DATE-TIME property value including its parameters, e.g. ";TZID=Europe/Berlin:20250327T090000"
+func formatTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + formatUTC(t)
+	}
+	return fmt.Sprintf(";TZID=%s:%s", loc.String(), t.In(loc).Format("20060102T150405"))
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// lineWriter writes content lines, folding them at maxLineOctets without splitting UTF-8 sequences
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(s string) {
+	if lw.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	_, lw.err = io.WriteString(lw.w, b.String())
+}
@@ -0,0 +1,130 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// observance is one onset of a STANDARD or DAYLIGHT component of a VTIMEZONE
+type observance struct {
+	onset      time.Time // the instant of the change
+	offsetFrom int       // seconds east of UTC before the change
+	offsetTo   int
+	name       string
+	dst        bool
+}
+
+// local is the onset on the wall clock as it read just before the change, which is how an
+// observance's DTSTART and RRULE describe it
+func (o observance) local() time.Time {
+	return o.onset.UTC().Add(time.Duration(o.offsetFrom) * time.Second)
+}
+
+// nextYear is when o's change happens the following year if it follows a yearly rule like
+// "the last Sunday of March at 02:00"
+func (o observance) nextYear() time.Time {
+	t := o.local()
+	ordinal := weekOrdinal(t)
+	day := time.Date(t.Year()+1, t.Month(), 1, t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	if ordinal < 0 {
+		day = day.AddDate(0, 1, -7)
+	}
+	day = day.AddDate(0, 0, (int(t.Weekday())-int(day.Weekday())+7)%7)
+	if ordinal > 0 {
+		day = day.AddDate(0, 0, 7*(ordinal-1))
+	}
+	return day.Add(-time.Duration(o.offsetFrom) * time.Second)
+}
+
+// weekOrdinal is the BYDAY ordinal of t's weekday within its month: -1 for the last one,
+// otherwise 1 to 4
+func weekOrdinal(t time.Time) int {
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		return -1
+	}
+	return (t.Day()-1)/7 + 1
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// writeTimezone writes the VTIMEZONE that TZID=<loc> refers to, covering from to until. Go
+// doesn't expose a zone's rules, so its changes in the period are looked up one by one and
+// those repeating every year by the same rule are written as a yearly RRULE.
+func writeTimezone(lw *lineWriter, loc *time.Location, from, until time.Time) {
+	// Start with the change that put the zone's offset at from in effect, or the epoch if it never changed
+	at, _ := from.In(loc).ZoneBounds()
+	if at.IsZero() {
+		_, offset := from.In(loc).Zone()
+		at = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(offset) * time.Second)
+	}
+	var standard, daylight []observance
+	for !at.IsZero() && at.Before(until) {
+		_, offsetFrom := at.Add(-time.Second).In(loc).Zone()
+		name, offsetTo := at.In(loc).Zone()
+		o := observance{onset: at, offsetFrom: offsetFrom, offsetTo: offsetTo, name: name, dst: at.In(loc).IsDST()}
+		if o.dst {
+			daylight = append(daylight, o)
+		} else {
+			standard = append(standard, o)
+		}
+		_, at = at.In(loc).ZoneBounds()
+	}
+
+	lw.line("BEGIN:VTIMEZONE")
+	lw.line("TZID:" + loc.String())
+	for _, changes := range [][]observance{standard, daylight} {
+		for i := 0; i < len(changes); {
+			last := i
+			for last+1 < len(changes) && changes[last].nextYear().Equal(changes[last+1].onset) &&
+				changes[last].offsetFrom == changes[last+1].offsetFrom && changes[last].offsetTo == changes[last+1].offsetTo &&
+				changes[last].name == changes[last+1].name {
+				last++
+			}
+			if last == i {
+				writeObservance(lw, changes[i], "")
+				i++
+				continue
+			}
+			start := changes[i].local()
+			rrule := fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", start.Month(), weekOrdinal(start), weekdayCodes[start.Weekday()])
+			if changes[last].nextYear().Before(until) {
+				// The zone stopped following the rule within the period
+				rrule += ";UNTIL=" + formatUTC(changes[last].onset)
+			}
+			writeObservance(lw, changes[i], rrule)
+			i = last + 1
+		}
+	}
+	lw.line("END:VTIMEZONE")
+}
+
+func writeObservance(lw *lineWriter, o observance, rrule string) {
+	kind := "STANDARD"
+	if o.dst {
+		kind = "DAYLIGHT"
+	}
+	lw.line("BEGIN:" + kind)
+	lw.line("DTSTART:" + o.local().Format("20060102T150405"))
+	if rrule != "" {
+		lw.line("RRULE:" + rrule)
+	}
+	lw.line("TZOFFSETFROM:" + formatOffset(o.offsetFrom))
+	lw.line("TZOFFSETTO:" + formatOffset(o.offsetTo))
+	if o.name != "" {
+		lw.line("TZNAME:" + escapeText(o.name))
+	}
+	lw.line("END:" + kind)
+}
+
+// formatOffset renders a UTC-OFFSET value such as -0500 or +0530
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
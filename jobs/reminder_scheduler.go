@@ -0,0 +1,295 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"todo-backend/models"
+	"todo-backend/notify"
+	"todo-backend/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	reminderBatchSize   = 20
+	reminderMaxAttempts = 8
+	// reminderLease is how long a claimed batch is reserved for this instance; sends must finish within it
+	reminderLease = 10 * time.Minute
+	// reminderLeaseMargin is kept in hand so a reminder isn't still being sent when its lease runs out
+	reminderLeaseMargin = 2 * time.Minute
+	// reminderSendTimeout bounds a single notification on a single channel
+	reminderSendTimeout = 20 * time.Second
+	// Occurrences missed by more than this (e.g. while the server was down) are dropped rather than sent late
+	reminderMaxLateness = 24 * time.Hour
+	reminderBaseBackoff = time.Minute
+	reminderMaxBackoff  = time.Hour
+)
+
+// ReminderScheduler delivers due reminders through every configured notifier. Several instances
+// can run against the same database: each batch is leased to one of them in a short transaction,
+// sent without holding any database locks and the outcome saved in another short transaction.
+type ReminderScheduler struct {
+	reminders  *repositories.ReminderRepository
+	notes      *repositories.NoteRepository
+	users      *repositories.UserRepository
+	collabRepo *repositories.CollaboratorRepository
+	notifiers  []notify.Notifier
+	interval   time.Duration
+}
+
+func NewReminderScheduler(db *gorm.DB, notifiers []notify.Notifier, interval time.Duration) *ReminderScheduler {
+	return &ReminderScheduler{
+		reminders:  repositories.NewReminderRepository(db),
+		notes:      repositories.NewNoteRepository(db),
+		users:      repositories.NewUserRepository(db),
+		collabRepo: repositories.NewCollaboratorRepository(db),
+		notifiers:  notifiers,
+		interval:   interval,
+	}
+}
+
+// Run delivers due reminders immediately and then on every interval until ctx is cancelled
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch works through due reminders batch by batch until none are left
+func (s *ReminderScheduler) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now()
+		lease := now.Add(reminderLease).Truncate(time.Second)
+		reminders, err := s.reminders.ClaimDue(repositories.DueReminderQuery{
+			Now:         now,
+			MaxAttempts: reminderMaxAttempts,
+			Limit:       reminderBatchSize,
+		}, lease)
+		if err != nil {
+			log.Printf("⚠️ Reminder dispatch failed: %v", err)
+			return
+		}
+
+		for i := range reminders {
+			// Whatever is left over is picked up again once the lease has run out
+			if ctx.Err() != nil || time.Until(lease) < reminderLeaseMargin {
+				log.Printf("⚠️ Reminder lease running out, %d reminders postponed", len(reminders)-i)
+				return
+			}
+			reminder := &reminders[i]
+			dueAt := *reminder.DueAt
+			s.deliver(ctx, reminder)
+			if err := s.reminders.RecordDelivery(reminder, dueAt, lease); err != nil {
+				log.Printf("⚠️ Reminder %s: could not save delivery: %v", reminder.ID, err)
+			}
+		}
+		if len(reminders) < reminderBatchSize {
+			return
+		}
+	}
+}
+
+// deliver sends a reminder to each recipient on each channel it hasn't reached them on yet and
+// leaves the outcome on it. Recurring reminders then move on to their next occurrence.
+func (s *ReminderScheduler) deliver(ctx context.Context, reminder *models.Reminder) {
+	dueAt := *reminder.DueAt
+	if time.Since(dueAt) > reminderMaxLateness {
+		if !s.advance(reminder, time.Now()) {
+			now := time.Now()
+			reminder.Status = models.ReminderStatusMissed
+			reminder.DeliveredAt = &now
+			reminder.NextAttemptAt = nil
+			reminder.LastError = fmt.Sprintf("missed: was due at %s", dueAt.Format(time.RFC3339))
+		}
+		return
+	}
+
+	done := make(map[string]bool)
+	for _, name := range strings.Split(reminder.DeliveredVia, ",") {
+		if name != "" {
+			done[name] = true
+		}
+	}
+
+	var failures []string
+	notifications, err := s.notifications(reminder)
+	var sent map[deliveryKey]bool
+	if err == nil {
+		sent, err = s.deliveries(reminder.ID, dueAt)
+	}
+	if err != nil {
+		failures = append(failures, err.Error())
+	} else {
+		for _, notifier := range s.notifiers {
+			if done[notifier.Name()] {
+				continue
+			}
+			if errs := s.sendAll(ctx, notifier, notifications, sent); len(errs) > 0 {
+				failures = append(failures, errs...)
+				continue
+			}
+			done[notifier.Name()] = true
+			if reminder.DeliveredVia != "" {
+				reminder.DeliveredVia += ","
+			}
+			reminder.DeliveredVia += notifier.Name()
+		}
+	}
+
+	reminder.Attempts++
+	if len(failures) == 0 {
+		if !s.advance(reminder, dueAt) {
+			now := time.Now()
+			reminder.DeliveredAt = &now
+			reminder.NextAttemptAt = nil
+			reminder.LastError = ""
+		}
+		reminder.Status = models.ReminderStatusFired
+		return
+	}
+
+	lastError := strings.Join(failures, "; ")
+	log.Printf("⚠️ Reminder %s failed (attempt %d/%d): %s", reminder.ID, reminder.Attempts, reminderMaxAttempts, lastError)
+	// A recurring reminder gives up on this occurrence rather than on every future one
+	if reminder.Attempts >= reminderMaxAttempts && s.advance(reminder, dueAt) {
+		return
+	}
+	next := time.Now().Add(reminderBackoff(reminder.Attempts))
+	reminder.NextAttemptAt = &next
+	reminder.LastError = lastError
+}
+
+// advance makes a recurring reminder due at its next occurrence after `after`, with fresh delivery
+// state. It reports false if the reminder doesn't repeat or has no occurrences left.
+func (s *ReminderScheduler) advance(reminder *models.Reminder, after time.Time) bool {
+	if reminder.RRule == "" {
+		return false
+	}
+	next, err := s.reminders.NextOccurrence(reminder, after)
+	if err != nil {
+		log.Printf("⚠️ Reminder %s: could not compute next occurrence: %v", reminder.ID, err)
+		return false
+	}
+	if next == nil {
+		return false
+	}
+	reminder.DueAt = next
+	if reminder.Status == models.ReminderStatusSnoozed {
+		reminder.Status = models.ReminderStatusPending
+	}
+	reminder.DeliveredAt = nil
+	reminder.DeliveredVia = ""
+	reminder.Attempts = 0
+	reminder.NextAttemptAt = nil
+	reminder.LastError = ""
+	return true
+}
+
+// notifications builds one notification per person on the reminder's note: the owner and every collaborator
+func (s *ReminderScheduler) notifications(reminder *models.Reminder) ([]notify.Notification, error) {
+	note, err := s.notes.GetByID(reminder.NoteID)
+	if err != nil {
+		return nil, err
+	}
+	recipients := []string{note.CreatedBy}
+	collaborators, err := s.collabRepo.GetAllByNote(reminder.NoteID)
+	if err != nil {
+		return nil, err
+	}
+	for _, collaborator := range collaborators {
+		recipients = append(recipients, collaborator.UserID)
+	}
+
+	var notifications []notify.Notification
+	for _, userID := range recipients {
+		n := notify.Notification{
+			ReminderID: reminder.ID,
+			NoteID:     reminder.NoteID,
+			UserID:     userID,
+			Title:      note.Title,
+			Body:       note.Description,
+			DueAt:      *reminder.DueAt,
+		}
+		user, err := s.users.FindByNoteUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			n.Email = user.Email
+			n.Name = user.FirstName
+			n.Location = repositories.PreferredLocation(user.Preferences)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// deliveryKey identifies a recipient on a channel
+type deliveryKey struct {
+	channel string
+	userID  string
+}
+
+// deliveries returns who an occurrence has already reached, and on which channels
+func (s *ReminderScheduler) deliveries(reminderID uuid.UUID, dueAt time.Time) (map[deliveryKey]bool, error) {
+	records, err := s.reminders.GetDeliveries(reminderID, dueAt)
+	if err != nil {
+		return nil, err
+	}
+	sent := make(map[deliveryKey]bool, len(records))
+	for _, d := range records {
+		sent[deliveryKey{d.Channel, d.UserID}] = true
+	}
+	return sent, nil
+}
+
+// sendAll sends each notification the notifier hasn't delivered yet, recording every success so
+// a retry skips it, and returns one error per recipient that failed
+func (s *ReminderScheduler) sendAll(ctx context.Context, notifier notify.Notifier, notifications []notify.Notification, sent map[deliveryKey]bool) []string {
+	var failures []string
+	for _, n := range notifications {
+		if sent[deliveryKey{notifier.Name(), n.UserID}] {
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, reminderSendTimeout)
+		err := notifier.Notify(sendCtx, n)
+		cancel()
+		if err == nil {
+			err = s.reminders.MarkDelivered(&models.ReminderDelivery{
+				ReminderID: n.ReminderID,
+				DueAt:      n.DueAt,
+				UserID:     n.UserID,
+				Channel:    notifier.Name(),
+			})
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s to %s: %v", notifier.Name(), n.UserID, err))
+		}
+	}
+	return failures
+}
+
+// reminderBackoff doubles the retry delay after each failed attempt, up to reminderMaxBackoff
+func reminderBackoff(attempts int) time.Duration {
+	delay := reminderBaseBackoff
+	for i := 1; i < attempts && delay < reminderMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > reminderMaxBackoff {
+		delay = reminderMaxBackoff
+	}
+	return delay
+}
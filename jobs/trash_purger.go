@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo-backend/repositories"
+
+	"gorm.io/gorm"
+)
+
+// TrashPurger permanently deletes notes that have been in the trash longer than the retention period
+type TrashPurger struct {
+	repo      *repositories.NoteRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(db *gorm.DB, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		repo:      repositories.NewNoteRepository(db),
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges expired notes immediately and then on every interval until ctx is cancelled
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge() {
+	cutoff := time.Now().Add(-p.retention)
+	n, err := p.repo.PurgeTrashedBefore(cutoff)
+	if err != nil {
+		log.Printf("⚠️ Trash purge failed: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Purged %d note(s) trashed before %s", n, cutoff.Format(time.RFC3339))
+	}
+}
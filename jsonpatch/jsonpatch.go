@@ -0,0 +1,281 @@
+// Package jsonpatch applies RFC 7396 JSON Merge Patch and RFC 6902 JSON Patch documents
+// to values decoded with encoding/json (maps, slices and scalars).
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrTestFailed = errors.New("test operation failed")
+
+// Operation is a single RFC 6902 operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies an RFC 7396 merge patch to doc and returns the result.
+// Objects are merged recursively, null removes a member and anything else replaces the target.
+func MergePatch(doc, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	target, ok := doc.(map[string]interface{})
+	if !ok {
+		target = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		target[key] = MergePatch(target[key], value)
+	}
+	return target
+}
+
+// Apply runs RFC 6902 operations against doc in order. doc is modified in place where possible;
+// always use the returned value.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	var err error
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			var value interface{}
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("operation %d: %s requires a value", i, op.Op)
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			switch op.Op {
+			case "add":
+				doc, err = add(doc, op.Path, value)
+			case "replace":
+				if op.Path == "" {
+					doc = value
+					break
+				}
+				if _, err = get(doc, op.Path); err == nil {
+					doc, err = remove(doc, op.Path)
+				}
+				if err == nil {
+					doc, err = add(doc, op.Path, value)
+				}
+			case "test":
+				var current interface{}
+				current, err = get(doc, op.Path)
+				if err == nil && !reflect.DeepEqual(current, value) {
+					err = ErrTestFailed
+				}
+			}
+		case "remove":
+			doc, err = remove(doc, op.Path)
+		case "move", "copy":
+			var value interface{}
+			value, err = get(doc, op.From)
+			if err == nil && op.Op == "move" {
+				if strings.HasPrefix(op.Path, op.From+"/") {
+					err = errors.New("cannot move a value into one of its children")
+				} else {
+					doc, err = remove(doc, op.From)
+				}
+			} else if err == nil {
+				value = deepCopy(value)
+			}
+			if err == nil {
+				doc, err = add(doc, op.Path, value)
+			}
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped reference tokens
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, path string) (interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	current := doc
+	for _, t := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[t]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", path)
+			}
+			current = value
+		case []interface{}:
+			i, err := arrayIndex(t, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", path)
+		}
+	}
+	return current, nil
+}
+
+// update walks to the parent of path and lets fn produce the parent's replacement. The root
+// has no parent, so callers handle path "" themselves.
+func update(doc interface{}, path string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("the root has no parent")
+	}
+
+	var walk func(node interface{}, tokens []string) (interface{}, error)
+	walk = func(node interface{}, tokens []string) (interface{}, error) {
+		if len(tokens) == 1 {
+			return fn(node, tokens[0])
+		}
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[tokens[0]]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", path)
+			}
+			updated, err := walk(child, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[tokens[0]] = updated
+			return n, nil
+		case []interface{}:
+			i, err := arrayIndex(tokens[0], len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			updated, err := walk(n[i], tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[i] = updated
+			return n, nil
+		default:
+			return nil, fmt.Errorf("path %q does not exist", path)
+		}
+	}
+	return walk(doc, tokens)
+}
+
+// add inserts value at path; adding at the root replaces the whole document
+func add(doc interface{}, path string, value interface{}) (interface{}, error) {
+	if path == "" {
+		return value, nil
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		default:
+			return nil, fmt.Errorf("path %q does not exist", path)
+		}
+	})
+}
+
+// remove deletes the value at path; removing the root leaves a null document
+func remove(doc interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return update(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("path %q does not exist", path)
+			}
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %q", path)
+		}
+	})
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[k] = deepCopy(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = deepCopy(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
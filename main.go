@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"time"
+	_ "time/tzdata" // reminder time zones must resolve even on hosts without a zoneinfo database
 
 	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 
 	"todo-backend/api/routes"
+	"todo-backend/auth"
 	"todo-backend/config"
+	"todo-backend/jobs"
+	"todo-backend/mail"
 	"todo-backend/models"
+	"todo-backend/notify"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
 )
 
 func main() {
@@ -27,19 +37,92 @@ func main() {
 		log.Printf("⚠️ Migration warning: %v", err)
 	}
 
+	hub := realtime.NewHub()
+
+	// Start background jobs
+	go jobs.NewTrashPurger(db, config.TrashRetention(), time.Hour).Run(context.Background())
+	go jobs.NewReminderScheduler(db, reminderNotifiers(db, hub), 30*time.Second).Run(context.Background())
+
 	// Setup and run the server
-	r := routes.SetupRoutes(db)
+	r := routes.SetupRoutes(db, hub, mailSender(), authenticator(db))
 	if err := r.Run(":8080"); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
+// reminderNotifiers returns the channels reminders are delivered on: always in-app, plus
+// email and webhook when configured
+func reminderNotifiers(db *gorm.DB, hub *realtime.Hub) []notify.Notifier {
+	notifiers := []notify.Notifier{notify.NewInAppNotifier(db, hub)}
+	if smtp, ok := config.SMTP(); ok {
+		notifiers = append(notifiers, notify.NewSMTPNotifier(mail.NewSMTPSender(smtp.Host, smtp.Port, smtp.Username, smtp.Password, smtp.From)))
+	}
+	if url, secret := config.ReminderWebhook(); url != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(url, secret))
+	}
+	return notifiers
+}
+
+// mailSender returns how account emails (e.g. password resets) are sent: over SMTP when configured,
+// only logged if MAIL_LOG_ONLY asks for that, and otherwise not at all (nil)
+func mailSender() mail.Sender {
+	smtp, ok := config.SMTP()
+	if !ok && config.MailLogOnly() {
+		log.Println("⚠️ MAIL_LOG_ONLY is set; account emails, reset links included, will only be logged")
+		return mail.LogSender{}
+	}
+	if !ok {
+		log.Println("⚠️ SMTP_HOST is not set; password resets are disabled")
+		return nil
+	}
+	return mail.NewSMTPSender(smtp.Host, smtp.Port, smtp.Username, smtp.Password, smtp.From)
+}
+
+// authenticator accepts the providers enabled in AUTH_PROVIDERS, trying them in that order
+func authenticator(db *gorm.DB) auth.Authenticator {
+	users := repositories.NewUserRepository(db)
+	var chain auth.Chain
+	for _, provider := range config.AuthProviders() {
+		switch provider {
+		case "clerk":
+			clerk := config.Clerk()
+			header := http.Header{}
+			if clerk.SecretKey != "" {
+				header.Set("Authorization", "Bearer "+clerk.SecretKey)
+			}
+			keys := auth.NewJWKS(clerk.JWKSURL, header)
+			chain = append(chain, auth.NewClerkAuthenticator(keys, clerk.Issuer, clerk.AuthorizedParties, users))
+		case "local":
+			chain = append(chain, auth.NewLocalAuthenticator(config.JWTSecret(), users))
+		case "test":
+			log.Println("⚠️ AUTH_PROVIDERS includes test: bearer tokens are trusted as user IDs without any check")
+			chain = append(chain, auth.NewTestAuthenticator(users))
+		default:
+			log.Fatalf("Unknown auth provider %q in AUTH_PROVIDERS", provider)
+		}
+	}
+	return chain
+}
+
 func AutoMigrate(db *gorm.DB) error {
 	for _, model := range []interface{}{
 		&models.User{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.StreamTicket{},
+		&models.Label{},
 		&models.Note{},
 		&models.ChecklistItem{},
 		&models.Reminder{},
+		&models.ReminderOverride{},
+		&models.ReminderDelivery{},
+		&models.NoteRevision{},
+		&models.NoteCollaborator{},
+		&models.NoteRevocation{},
+		&models.NoteShareLink{},
+		&models.Notification{},
+		&models.CalendarFeed{},
+		&models.WebhookEvent{},
 	} {
 		log.Printf("Migrating: %T", model)
 		if err := db.Migrator().AutoMigrate(model); err != nil {
@@ -47,6 +130,26 @@ func AutoMigrate(db *gorm.DB) error {
 			return err
 		}
 	}
+	if err := repositories.NewUserRepository(db).EnsureClerkIDIndex(); err != nil {
+		log.Printf("⚠️ Failed to replace the clerk_id index: %v", err)
+	}
+	noteRepo := repositories.NewNoteRepository(db)
+	if err := noteRepo.EnsureSearchIndexes(); err != nil {
+		log.Printf("⚠️ Failed to create search indexes: %v", err)
+	}
+	if err := noteRepo.EnsureRanks(); err != nil {
+		log.Printf("⚠️ Failed to rank notes and checklist items: %v", err)
+	}
+	if err := repositories.NewRevisionRepository(db).EnsureBaselines(); err != nil {
+		log.Printf("⚠️ Failed to record the first revision of existing notes: %v", err)
+	}
+	reminderRepo := repositories.NewReminderRepository(db)
+	if err := reminderRepo.EnsureDueTimes(); err != nil {
+		log.Printf("⚠️ Failed to schedule existing reminders: %v", err)
+	}
+	if err := reminderRepo.EnsureStatuses(); err != nil {
+		log.Printf("⚠️ Failed to set reminder statuses: %v", err)
+	}
 	log.Println("✅ All migrations attempted.")
 	return nil
 }
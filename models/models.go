@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,15 +12,92 @@ import (
 
 type User struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	ClerkID   string    `json:"clerkId" gorm:"uniqueIndex"`
+	ClerkID   string    `json:"clerkId" gorm:"uniqueIndex:idx_users_clerk_id_unique,where:clerk_id <> ''"` // empty for email and password accounts
 	Email     string    `json:"email"`
 	FirstName string    `json:"firstName"`
 	LastName  string    `json:"lastName"`
 	ImageURL  string    `json:"imageUrl"`
-	Password  string    `gorm:"size:255" json:"password"`
+	Password  string    `gorm:"size:255" json:"-"` // bcrypt hash; empty for Clerk accounts
+	Role      string    `gorm:"size:16;not null;default:'user'" json:"role"`
+	// EmailVerified is set once the user proved they own Email, through Clerk or a password
+	// reset; only verified addresses can be invited to notes
+	EmailVerified bool `gorm:"not null;default:false" json:"emailVerified"`
+	// ClerkUpdatedAt is when Clerk last changed the profile we copied, so late webhooks can't roll it back
+	ClerkUpdatedAt *time.Time `json:"-"`
+	// Preferences live on the user row as pref_* columns
+	Preferences UserPreferences `gorm:"embedded;embeddedPrefix:pref_" json:"preferences"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin" // may list, read, change and delete any user
+)
+
+// RefreshToken is one link in a session's chain of refresh tokens, stored as a SHA-256 hash.
+// Refreshing uses the token up and issues the next one in the same family; a used token
+// coming back means it was copied, so the whole family (the session) is revoked.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// PasswordResetToken lets whoever holds the emailed link set a new password, once, before ExpiresAt.
+// Only its SHA-256 hash is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// StreamTicket lets an EventSource, which can't send an Authorization header, open the note
+// stream once, shortly after the signed-in user asked for it. Only its SHA-256 hash is stored.
+type StreamTicket struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string    `gorm:"not null"` // as notes store it (Clerk ID or UUID)
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// WebhookEvent records a webhook delivery we have processed, by the sender's message ID, so
+// redeliveries are acknowledged without being applied twice
+type WebhookEvent struct {
+	ID        string    `gorm:"size:255;primaryKey"`
+	Source    string    `gorm:"size:32;not null"`
+	Type      string    `gorm:"size:64;not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// UserPreferences are per-user settings. An empty Timezone means UTC; DefaultReminderTime is
+// "HH:MM", the time of day used when a reminder is given a day but no time.
+type UserPreferences struct {
+	Timezone            string `gorm:"size:64;not null;default:''" json:"timezone"`
+	WeekStart           string `gorm:"size:16;not null;default:'monday'" json:"week_start"`
+	DefaultNoteSort     string `gorm:"size:16;not null;default:'rank'" json:"default_note_sort"`
+	DefaultReminderTime string `gorm:"size:5;not null;default:'09:00'" json:"default_reminder_time"`
+	Theme               string `gorm:"size:16;not null;default:'system'" json:"theme"`
+}
+
+// DefaultUserPreferences are the preferences of a user who hasn't changed any, matching the column defaults
+func DefaultUserPreferences() UserPreferences {
+	return UserPreferences{
+		WeekStart:           "monday",
+		DefaultNoteSort:     "rank",
+		DefaultReminderTime: "09:00",
+		Theme:               "system",
+	}
 }
 
 type Note struct {
@@ -27,28 +107,201 @@ type Note struct {
 	IsPinned       bool            `json:"isPinned"`
 	IsArchived     bool            `json:"isArchived"`
 	IsChecklist    bool            `json:"isChecklist"`
+	Version        int             `gorm:"not null;default:1" json:"version"`
+	Rank           string          `gorm:"size:255;not null;default:''" json:"rank"`
 	ChecklistItems []ChecklistItem `gorm:"foreignKey:NoteID" json:"checklistItems"`
 	Reminders      []Reminder      `gorm:"foreignKey:NoteID" json:"reminders"`
+	Labels         []Label         `gorm:"many2many:note_labels;" json:"labels"`
 	CreatedBy      string          `gorm:"not null" json:"created_by"`
 	CreatedAt      time.Time       `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedBy      string          `gorm:"not null" json:"updated_by"`
-	UpdatedAt      time.Time       `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	UpdatedAt      time.Time       `gorm:"default:CURRENT_TIMESTAMP;index" json:"updated_at"`
 	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"deleted_at"`
 }
 
 type ChecklistItem struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	NoteID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Note      Note       `gorm:"foreignKey:NoteID;references:ID"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	Text      string     `json:"text"`
+	IsChecked bool       `json:"isChecked"`
+	Rank      string     `gorm:"size:255;not null;default:''" json:"rank"`
+	CreatedAt time.Time
+	UpdatedAt time.Time      `gorm:"index"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type Reminder struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	NoteID    uuid.UUID `gorm:"type:uuid;not null;index"`
 	Note      Note      `gorm:"foreignKey:NoteID;references:ID"`
+	Time      time.Time `json:"time"`
+	RRule     string    `gorm:"column:rrule;size:500" json:"rrule"` // iCalendar recurrence rule, empty for one-off reminders
+	Timezone  string    `gorm:"size:64" json:"timezone"`            // IANA zone the recurrence is evaluated in, UTC when empty
+	Status    string    `gorm:"size:16;not null;default:'pending'" json:"status"`
+	CreatedAt time.Time
+	UpdatedAt time.Time      `gorm:"index"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	// Delivery state, maintained by the reminder scheduler. DueAt is the occurrence being
+	// delivered: Time for one-off reminders, the next occurrence for recurring ones.
+	DueAt         *time.Time `gorm:"index" json:"-"`
+	DeliveredAt   *time.Time `gorm:"index" json:"-"`
+	DeliveredVia  string     `gorm:"size:255" json:"-"` // comma-separated names of notifiers that reached every recipient
+	Attempts      int        `gorm:"not null;default:0" json:"-"`
+	NextAttemptAt *time.Time `json:"-"` // the next retry, or until when a scheduler has claimed the reminder
+	LastError     string     `gorm:"type:text" json:"-"`
+}
+
+// Reminder lifecycle. A recurring reminder's status follows its latest occurrence; it keeps
+// firing on schedule whatever the status.
+const (
+	ReminderStatusPending   = "pending"   // waiting to fire
+	ReminderStatusFired     = "fired"     // delivered, not yet acted on
+	ReminderStatusSnoozed   = "snoozed"   // fired, then put off until DueAt
+	ReminderStatusDismissed = "dismissed" // acknowledged (or cancelled before firing)
+	ReminderStatusMissed    = "missed"    // too late to deliver by the time it was picked up
+)
+
+// ReminderDelivery records that one occurrence of a reminder reached one recipient on one
+// channel, so a retry after a partial failure only sends what is still missing
+type ReminderDelivery struct {
+	ReminderID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	DueAt      time.Time `gorm:"primaryKey"`
+	UserID     string    `gorm:"primaryKey"`
+	Channel    string    `gorm:"size:32;primaryKey"`
+	CreatedAt  time.Time
+}
+
+// ReminderOverride changes one occurrence of a recurring reminder: it moves the occurrence
+// due at OriginalTime to Time, or skips it when Time is nil
+type ReminderOverride struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ReminderID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_reminder_override" json:"reminder_id"`
+	OriginalTime time.Time  `gorm:"not null;uniqueIndex:idx_reminder_override" json:"original_time"`
+	Time         *time.Time `json:"time"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Notification is an in-app message for a user, e.g. a reminder that fired
+type Notification struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID     string     `gorm:"not null;index;uniqueIndex:idx_notification_occurrence" json:"user_id"`
+	NoteID     *uuid.UUID `gorm:"type:uuid;index" json:"note_id"`
+	ReminderID *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_notification_occurrence" json:"reminder_id"`
+	Title      string     `gorm:"size:255" json:"title"`
+	Body       string     `gorm:"type:text" json:"body"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+	// OccurrenceAt is the reminder occurrence this notifies about; each user gets one notification per occurrence
+	OccurrenceAt *time.Time `gorm:"uniqueIndex:idx_notification_occurrence" json:"occurrence_at,omitempty"`
+}
+
+type Label struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex:idx_label_owner_name" json:"name"`
+	Color     string    `gorm:"size:20" json:"color"`
+	CreatedBy string    `gorm:"not null;uniqueIndex:idx_label_owner_name" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	NoteRoleOwner  = "owner"
+	NoteRoleEditor = "editor"
+	NoteRoleViewer = "viewer"
+)
+
+// NoteCollaborator grants another user access to a note
+type NoteCollaborator struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	NoteID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_note_collaborator" json:"note_id"`
+	UserID    string    `gorm:"not null;uniqueIndex:idx_note_collaborator;index" json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `gorm:"size:20;not null" json:"role"`
+	InvitedBy string    `gorm:"not null" json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NoteRevocation records that a collaborator lost access to a note, or that a note was purged
+// from the trash, so the user's next sync pull drops it. It is removed when the note is shared
+// with them again, and otherwise once the trash retention has passed.
+type NoteRevocation struct {
+	NoteID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"note_id"`
+	UserID    string    `gorm:"primaryKey;index" json:"user_id"`
+	RevokedAt time.Time `gorm:"not null;index" json:"revoked_at"`
+}
+
+// NoteShareLink is a public, read-only link to a note. Only a hash of the token is stored.
+type NoteShareLink struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	NoteID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"note_id"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	PasswordHash string     `gorm:"size:255" json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedBy    string     `gorm:"not null" json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// CalendarFeed is a user's secret iCalendar subscription URL for their reminders. Only the
+// token's hash is stored; rotating the token replaces it and breaks the old URL.
+type CalendarFeed struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	UserID    string    `gorm:"not null;uniqueIndex" json:"user_id"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NoteRevision is an immutable snapshot of a note taken after each change
+type NoteRevision struct {
+	ID          uuid.UUID         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	NoteID      uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_note_revision" json:"note_id"`
+	Revision    int               `gorm:"not null;uniqueIndex:idx_note_revision" json:"revision"`
+	Title       string            `gorm:"size:255" json:"title"`
+	Description string            `gorm:"type:text" json:"description"`
+	IsPinned    bool              `json:"isPinned"`
+	IsArchived  bool              `json:"isArchived"`
+	IsChecklist bool              `json:"isChecklist"`
+	Checklist   ChecklistSnapshot `gorm:"type:jsonb" json:"checklist"`
+	EditedBy    string            `gorm:"not null" json:"edited_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type ChecklistSnapshotItem struct {
+	ID        uuid.UUID `json:"id"`
+	ParentID  uuid.UUID `json:"parent_id"` // uuid.Nil for top-level items
 	Text      string    `json:"text"`
 	IsChecked bool      `json:"isChecked"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
 }
 
-type Reminder struct {
-	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	NoteID uuid.UUID `gorm:"type:uuid;not null;index"`
-	Note   Note      `gorm:"foreignKey:NoteID;references:ID"`
-	Time   time.Time `json:"time"`
+// ChecklistSnapshot is stored as a JSONB column on NoteRevision
+type ChecklistSnapshot []ChecklistSnapshotItem
+
+func (s ChecklistSnapshot) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *ChecklistSnapshot) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	case nil:
+		*s = nil
+		return nil
+	default:
+		return errors.New("unsupported type for ChecklistSnapshot")
+	}
+	return json.Unmarshal(raw, s)
 }
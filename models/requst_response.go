@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +16,36 @@ type CreateUserRequest struct {
 	Password  string `json:"password"`
 }
 
+// UpdateUserRequest changes only the fields that are present. Only admins may change a Role.
+type UpdateUserRequest struct {
+	FirstName *string `json:"firstName"`
+	LastName  *string `json:"lastName"`
+	ImageURL  *string `json:"imageUrl"`
+	Role      *string `json:"role" binding:"omitempty,oneof=user admin"`
+}
+
+// UserResponse is a user as the API shows them; credentials never leave the server
+type UserResponse struct {
+	ID        uuid.UUID `json:"id"`
+	ClerkID   string    `json:"clerkId,omitempty"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	ImageURL  string    `json:"imageUrl"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpdatePreferencesRequest replaces the caller's preferences. Timezone is an IANA name, or empty for UTC.
+type UpdatePreferencesRequest struct {
+	Timezone            string `json:"timezone"`
+	WeekStart           string `json:"week_start" binding:"required,oneof=monday sunday saturday"`
+	DefaultNoteSort     string `json:"default_note_sort" binding:"required,oneof=updated_at created_at title rank"`
+	DefaultReminderTime string `json:"default_reminder_time" binding:"required"`
+	Theme               string `json:"theme" binding:"required,oneof=system light dark"`
+}
+
 // request model
 type CreateNoteRequest struct {
 	Title          string            `json:"title" binding:"required"`
@@ -24,6 +55,31 @@ type CreateNoteRequest struct {
 	IsChecklist    bool              `json:"isChecklist"`
 	ChecklistItems []ChecklistItem   `json:"checklistItems"`
 	Reminders      []ReminderRequest `json:"reminders"`
+	Labels         []string          `json:"labels"`
+}
+
+// QuickAddRequest is a line of free text to turn into a note, e.g. "Buy milk tomorrow 6pm #groceries !pin".
+// Relative dates are resolved in Timezone (IANA name, UTC by default); DryRun only parses.
+type QuickAddRequest struct {
+	Text     string `json:"text" binding:"required"`
+	Timezone string `json:"timezone"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+// QuickAddParsed is what quick-add understood, for the client to show back to the user.
+// Phrases are the bits of text read as the reminder's date, time or repeat.
+type QuickAddParsed struct {
+	Title      string           `json:"title"`
+	Labels     []string         `json:"labels"`
+	IsPinned   bool             `json:"isPinned"`
+	IsArchived bool             `json:"isArchived"`
+	Reminder   *ReminderRequest `json:"reminder,omitempty"`
+	Phrases    []string         `json:"phrases,omitempty"`
+}
+
+type QuickAddResponse struct {
+	Parsed QuickAddParsed `json:"parsed"`
+	Note   *NoteResponse  `json:"note,omitempty"`
 }
 
 // response model
@@ -35,32 +91,354 @@ type NoteResponse struct {
 	IsPinned       bool                    `json:"isPinned"`
 	IsArchived     bool                    `json:"isArchived"`
 	IsChecklist    bool                    `json:"isChecklist"`
+	Version        int                     `json:"version"`
+	Rank           string                  `json:"rank"`
+	Completion     *int                    `json:"completion,omitempty"` // percent of checked leaf items
 	CreatedAt      time.Time               `json:"created_at"`
 	UpdatedAt      time.Time               `json:"updated_at"`
 	CreatedBy      string                  `json:"created_by"`
 	UpdatedBy      string                  `json:"updated_by"`
+	DeletedAt      *time.Time              `json:"deleted_at,omitempty"`
 	ChecklistItems []ChecklistItemResponse `json:"checklist_items,omitempty"`
 	Reminders      []ReminderResponse      `json:"reminders,omitempty"`
+	Labels         []string                `json:"labels,omitempty"`
 }
 
 type NoteListResponse struct {
-	Total int            `json:"total"`
-	Notes []NoteResponse `json:"notes"`
+	Total      int64          `json:"total"`
+	Notes      []NoteResponse `json:"notes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
 }
+
+// NotePatchDocument is the JSON shape PATCH /notes/:id operates on. Checklist items and reminders are
+// keyed by ID so a merge patch can add, change or remove (with null) individual entries; new entries
+// use a client-generated UUID as their key.
+type NotePatchDocument struct {
+	Title          string                        `json:"title"`
+	Description    string                        `json:"description"`
+	IsPinned       bool                          `json:"isPinned"`
+	IsArchived     bool                          `json:"isArchived"`
+	IsChecklist    bool                          `json:"isChecklist"`
+	Labels         []string                      `json:"labels"`
+	ChecklistItems map[string]PatchChecklistItem `json:"checklistItems"`
+	Reminders      map[string]PatchReminder      `json:"reminders"`
+}
+
+type PatchChecklistItem struct {
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	Text      string     `json:"text"`
+	IsChecked bool       `json:"isChecked"`
+}
+
+type PatchReminder struct {
+	Time     time.Time `json:"time"`
+	RRule    string    `json:"rrule,omitempty"`
+	Timezone string    `json:"timezone,omitempty"`
+}
+
+type NoteSearchHighlights struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Checklist   string `json:"checklist,omitempty"`
+}
+
+type NoteSearchResult struct {
+	Note       NoteResponse         `json:"note"`
+	Rank       float64              `json:"rank"`
+	Highlights NoteSearchHighlights `json:"highlights"`
+}
+
+type NoteSearchResponse struct {
+	Query   string             `json:"query"`
+	Total   int                `json:"total"`
+	Results []NoteSearchResult `json:"results"`
+}
+
+// ChecklistItemResponse is a checklist item with its sub-tasks nested under Children
 type ChecklistItemResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Text      string    `json:"text"`
-	IsChecked bool      `json:"isChecked"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID               `json:"id"`
+	ParentID  *uuid.UUID              `json:"parent_id,omitempty"`
+	Text      string                  `json:"text"`
+	IsChecked bool                    `json:"isChecked"`
+	Rank      string                  `json:"rank"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+	Children  []ChecklistItemResponse `json:"children,omitempty"`
 }
 
+type CreateChecklistItemRequest struct {
+	Text      string     `json:"text" binding:"required"`
+	IsChecked bool       `json:"isChecked"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+}
+
+// UpdateChecklistItemRequest only changes the fields that are present. An empty
+// parent_id moves the item back to the top level.
+type UpdateChecklistItemRequest struct {
+	Text      *string `json:"text"`
+	IsChecked *bool   `json:"isChecked"`
+	ParentID  *string `json:"parent_id"`
+}
+
+// ReorderChecklistRequest lists every live item of a note in its new order
+type ReorderChecklistRequest struct {
+	ItemIDs []uuid.UUID `json:"item_ids" binding:"required"`
+}
+
+// MoveRequest places a note or checklist item between two neighbours. Either side may be
+// omitted to move to the start (no after_id) or end (no before_id) of the list.
+type MoveRequest struct {
+	AfterID  *uuid.UUID `json:"after_id"`
+	BeforeID *uuid.UUID `json:"before_id"`
+}
+
+// ReminderRequest sets a reminder. With RRule (e.g. "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR") it repeats,
+// starting at Time; Timezone (IANA name, UTC by default) keeps occurrences at the same local time.
 type ReminderRequest struct {
-	Time time.Time `json:"time"`
+	Time     time.Time `json:"time"`
+	RRule    string    `json:"rrule"`
+	Timezone string    `json:"timezone"`
 }
 
 type ReminderResponse struct {
-	Time time.Time `json:"time"`
+	ID           uuid.UUID   `json:"id"`
+	Time         time.Time   `json:"time"`
+	RRule        string      `json:"rrule,omitempty"`
+	Timezone     string      `json:"timezone,omitempty"`
+	Status       string      `json:"status"`
+	SnoozedUntil *time.Time  `json:"snoozed_until,omitempty"`
+	Upcoming     []time.Time `json:"upcoming,omitempty"` // next occurrences, with skipped and moved ones applied
+}
+
+// SnoozeRequest puts a fired reminder off, either by a preset or until an explicit time
+type SnoozeRequest struct {
+	Preset string     `json:"preset" binding:"omitempty,oneof=10m 1h 3h tomorrow next_week"`
+	Until  *time.Time `json:"until"`
+}
+
+// UpcomingReminderResponse is one upcoming occurrence of a reminder on any of the user's notes
+type UpcomingReminderResponse struct {
+	ReminderID uuid.UUID `json:"reminder_id"`
+	NoteID     uuid.UUID `json:"note_id"`
+	NoteTitle  string    `json:"note_title"`
+	Time       time.Time `json:"time"`
+	Status     string    `json:"status"`
+	Recurring  bool      `json:"recurring"`
+}
+
+// SkipOccurrenceRequest names one occurrence of a recurring reminder by the time it's due
+type SkipOccurrenceRequest struct {
+	Occurrence time.Time `json:"occurrence" binding:"required"`
+}
+
+// RescheduleOccurrenceRequest moves one occurrence of a recurring reminder to Time
+type RescheduleOccurrenceRequest struct {
+	Occurrence time.Time `json:"occurrence" binding:"required"`
+	Time       time.Time `json:"time" binding:"required"`
+}
+
+// CalendarFeedResponse describes a user's calendar subscription. URL carries the secret token,
+// so it is only filled in right after the token is (re)generated.
+type CalendarFeedResponse struct {
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NotificationResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	NoteID     *uuid.UUID `json:"note_id,omitempty"`
+	ReminderID *uuid.UUID `json:"reminder_id,omitempty"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type NoteRevisionResponse struct {
+	Revision    int                     `json:"revision"`
+	Title       string                  `json:"title"`
+	Description string                  `json:"description"`
+	IsPinned    bool                    `json:"isPinned"`
+	IsArchived  bool                    `json:"isArchived"`
+	IsChecklist bool                    `json:"isChecklist"`
+	Checklist   []ChecklistSnapshotItem `json:"checklist"`
+	EditedBy    string                  `json:"edited_by"`
+	CreatedAt   time.Time               `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type ChecklistItemChange struct {
+	ID     uuid.UUID              `json:"id"`
+	Change string                 `json:"change"` // added, removed or modified
+	From   *ChecklistSnapshotItem `json:"from,omitempty"`
+	To     *ChecklistSnapshotItem `json:"to,omitempty"`
+}
+
+type NoteRevisionDiffResponse struct {
+	From      int                   `json:"from"`
+	To        int                   `json:"to"`
+	Fields    []FieldChange         `json:"fields"`
+	Checklist []ChecklistItemChange `json:"checklist"`
+}
+
+type InviteCollaboratorRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+type UpdateCollaboratorRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+type CollaboratorResponse struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateShareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Password  string     `json:"password"`
+}
+
+type ShareLinkResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Token       string     `json:"token,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	HasPassword bool       `json:"has_password"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type SharedNoteResponse struct {
+	Title          string                  `json:"title"`
+	Description    string                  `json:"description"`
+	IsChecklist    bool                    `json:"isChecklist"`
+	ChecklistItems []ChecklistItemResponse `json:"checklist_items,omitempty"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+}
+
+type SyncNote struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	IsPinned    bool       `json:"isPinned"`
+	IsArchived  bool       `json:"isArchived"`
+	IsChecklist bool       `json:"isChecklist"`
+	Version     int        `json:"version"`
+	Rank        string     `json:"rank"`
+	CreatedBy   string     `json:"created_by"`
+	UpdatedBy   string     `json:"updated_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+type SyncChecklistItem struct {
+	ID        uuid.UUID  `json:"id"`
+	NoteID    uuid.UUID  `json:"note_id"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	Text      string     `json:"text"`
+	IsChecked bool       `json:"isChecked"`
+	Rank      string     `json:"rank"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type SyncReminder struct {
+	ID        uuid.UUID  `json:"id"`
+	NoteID    uuid.UUID  `json:"note_id"`
+	Time      time.Time  `json:"time"`
+	RRule     string     `json:"rrule"`
+	Timezone  string     `json:"timezone"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// SyncPullResponse carries changes since the client's token. When Full is true the client must
+// replace its local copy instead of merging, because its token was too old to diff against.
+type SyncPullResponse struct {
+	Token          string              `json:"token"`
+	Full           bool                `json:"full"`
+	Notes          []SyncNote          `json:"notes"`
+	ChecklistItems []SyncChecklistItem `json:"checklist_items"`
+	Reminders      []SyncReminder      `json:"reminders"`
+}
+
+// SyncMutation is a single client-side change. BaseUpdatedAt is the updated_at the client last saw;
+// if the server copy is newer the mutation is rejected as a conflict.
+type SyncMutation struct {
+	Entity        string           `json:"entity" binding:"required,oneof=note checklist_item reminder"`
+	Op            string           `json:"op" binding:"required,oneof=upsert delete"`
+	ID            uuid.UUID        `json:"id" binding:"required"`
+	BaseUpdatedAt *time.Time       `json:"base_updated_at"`
+	Data          SyncMutationData `json:"data"`
+}
+
+// SyncMutationData holds the fields to write; only supplied fields are changed
+type SyncMutationData struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	IsPinned    *bool      `json:"isPinned"`
+	IsArchived  *bool      `json:"isArchived"`
+	IsChecklist *bool      `json:"isChecklist"`
+	NoteID      *uuid.UUID `json:"note_id"`
+	Text        *string    `json:"text"`
+	IsChecked   *bool      `json:"isChecked"`
+	Time        *time.Time `json:"time"`
+	RRule       *string    `json:"rrule"`
+	Timezone    *string    `json:"timezone"`
+}
+
+type SyncPushRequest struct {
+	Mutations []SyncMutation `json:"mutations" binding:"required,dive"`
+}
+
+type SyncMutationResult struct {
+	Entity string      `json:"entity"`
+	ID     uuid.UUID   `json:"id"`
+	Status string      `json:"status"` // applied, conflict, not_found, forbidden or invalid
+	Error  string      `json:"error,omitempty"`
+	Server interface{} `json:"server,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Token   string               `json:"token"`
+	Results []SyncMutationResult `json:"results"`
+}
+
+type LabelRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Color string `json:"color" binding:"max=20"`
+}
+
+type LabelResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RegisterRequest signs up with an email address and password
+type RegisterRequest struct {
+	FirstName string `json:"firstName" binding:"required"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password" binding:"required,min=8,max=72"`
 }
 
 type LoginRequest struct {
@@ -79,3 +457,51 @@ type RefreshTokenRequest struct {
 type ForgotPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
+
+// ResetPasswordRequest sets a new password with the token from a reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
+// ClerkWebhookEvent is the envelope of a Clerk webhook; the shape of Data depends on Type
+type ClerkWebhookEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ClerkUserData is the user Clerk sends with user.* events, reduced to the fields we keep.
+// For user.deleted only ID (and Deleted) are set.
+type ClerkUserData struct {
+	ID                    string              `json:"id"`
+	FirstName             *string             `json:"first_name"`
+	LastName              *string             `json:"last_name"`
+	ImageURL              string              `json:"image_url"`
+	PrimaryEmailAddressID *string             `json:"primary_email_address_id"`
+	EmailAddresses        []ClerkEmailAddress `json:"email_addresses"`
+	Deleted               bool                `json:"deleted"`
+	UpdatedAt             int64               `json:"updated_at"` // Unix milliseconds
+}
+
+type ClerkEmailAddress struct {
+	ID           string `json:"id"`
+	EmailAddress string `json:"email_address"`
+	Verification *struct {
+		Status string `json:"status"` // "verified" once the user proved they own the address
+	} `json:"verification"`
+}
+
+// AuthResponse is a new access token and the refresh token to get the next one with
+type AuthResponse struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"` // seconds until the access token expires
+	UserID       uuid.UUID `json:"user_id"`
+}
+
+// StreamTicketResponse is a single-use ticket for opening the note stream as ?ticket=
+type StreamTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"` // seconds until the ticket can no longer be used
+}
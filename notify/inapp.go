@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"todo-backend/models"
+	"todo-backend/realtime"
+	"todo-backend/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InAppNotifier stores notifications for GET /notifications and pushes them to connected clients
+type InAppNotifier struct {
+	repo   *repositories.NotificationRepository
+	broker realtime.Broker
+}
+
+func NewInAppNotifier(db *gorm.DB, broker realtime.Broker) *InAppNotifier {
+	return &InAppNotifier{repo: repositories.NewNotificationRepository(db), broker: broker}
+}
+
+func (a *InAppNotifier) Name() string {
+	return "in_app"
+}
+
+// Notify stores the notification and pushes it. A retry for the same occurrence stores nothing
+// new, so the user never sees a reminder twice.
+func (a *InAppNotifier) Notify(ctx context.Context, n Notification) error {
+	noteID, reminderID, dueAt := n.NoteID, n.ReminderID, n.DueAt
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       n.UserID,
+		NoteID:       &noteID,
+		ReminderID:   &reminderID,
+		OccurrenceAt: &dueAt,
+		Title:        n.Title,
+		Body:         n.Body,
+	}
+	created, err := a.repo.CreateOnce(&notification)
+	if err != nil || !created {
+		return err
+	}
+
+	a.broker.Publish(realtime.Event{
+		Type:       realtime.EventNotification,
+		NoteID:     noteID,
+		Data:       notification,
+		Recipients: []string{n.UserID},
+	})
+	return nil
+}
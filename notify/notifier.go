@@ -0,0 +1,30 @@
+// Package notify delivers reminder notifications to users over pluggable channels.
+package notify
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification is one message for one recipient
+type Notification struct {
+	ReminderID uuid.UUID
+	NoteID     uuid.UUID
+	UserID     string // the ID notes use for the recipient (Clerk ID or user UUID)
+	Email      string
+	Name       string
+	Title      string
+	Body       string
+	DueAt      time.Time
+	Location   *time.Location // the recipient's time zone, for showing DueAt; UTC if nil
+}
+
+// Notifier sends notifications over a single channel. Name identifies the channel in the
+// delivery record so a reminder that partially failed is only retried on the channels
+// that haven't succeeded yet.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"todo-backend/mail"
+)
+
+// SMTPNotifier emails notifications through a mail.Sender
+type SMTPNotifier struct {
+	sender mail.Sender
+}
+
+func NewSMTPNotifier(sender mail.Sender) *SMTPNotifier {
+	return &SMTPNotifier{sender: sender}
+}
+
+func (s *SMTPNotifier) Name() string {
+	return "email"
+}
+
+// Notify sends one email. Recipients without an email address are skipped.
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return nil
+	}
+	return s.sender.Send(ctx, mail.Message{To: n.Email, Subject: "Reminder: " + n.Title, Body: body(n)})
+}
+
+func body(n Notification) string {
+	var b strings.Builder
+	if n.Name != "" {
+		fmt.Fprintf(&b, "Hi %s,\n\n", n.Name)
+	}
+	loc := n.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	fmt.Fprintf(&b, "This is your reminder for \"%s\", due %s.\n", n.Title, n.DueAt.In(loc).Format("Mon, 02 Jan 2006 15:04 MST"))
+	if n.Body != "" {
+		b.WriteString("\n")
+		b.WriteString(n.Body)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookNotifier POSTs notifications as JSON to a URL. When a secret is set the body is
+// signed with HMAC-SHA256 in the X-Signature header as "sha256=<hex>". A retried delivery
+// carries the same ID (also in the Idempotency-Key header), so receivers can drop duplicates.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type webhookPayload struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	ReminderID uuid.UUID `json:"reminder_id"`
+	NoteID     uuid.UUID `json:"note_id"`
+	UserID     string    `json:"user_id"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	DueAt      time.Time `json:"due_at"`
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	id := fmt.Sprintf("%s:%d:%s", n.ReminderID, n.DueAt.Unix(), n.UserID)
+	body, err := json.Marshal(webhookPayload{
+		ID:         id,
+		Event:      "reminder.due",
+		ReminderID: n.ReminderID,
+		NoteID:     n.NoteID,
+		UserID:     n.UserID,
+		Title:      n.Title,
+		Body:       n.Body,
+		DueAt:      n.DueAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", id)
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,534 @@
+// Package quickadd turns a line of free text such as "Buy milk tomorrow 6pm #groceries !pin"
+// into the parts of a note: a title, labels (#name), flags (!pin, !archive) and a reminder time,
+// optionally repeating ("every monday at 9"). Recognised words are removed from the title.
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Times of day used for a part of the day
+const (
+	morningHour   = 9
+	afternoonHour = 15
+	eveningHour   = 18
+	tonightHour   = 20
+)
+
+type Result struct {
+	Title    string     `json:"title"`
+	Labels   []string   `json:"labels"`
+	Pinned   bool       `json:"pinned"`
+	Archived bool       `json:"archived"`
+	Reminder *time.Time `json:"reminder,omitempty"`
+	RRule    string     `json:"rrule,omitempty"`
+	// Phrases lists the pieces of text that were read as dates, times or repeats
+	Phrases []string `json:"phrases,omitempty"`
+}
+
+// Options carries the user's preferences that affect how dates are read
+type Options struct {
+	// DefaultHour and DefaultMinute are the time used when the text names a day but no time
+	DefaultHour   int
+	DefaultMinute int
+	// WeekStart is the first day of the week; "next week" means the next one
+	WeekStart time.Weekday
+}
+
+// Parse reads text relative to now, whose location is the user's time zone
+func Parse(text string, now time.Time, opts Options) Result {
+	p := parser{now: now, opts: opts, tokens: strings.Fields(text), result: Result{Labels: []string{}}}
+	for _, tok := range p.tokens {
+		p.words = append(p.words, normalize(tok))
+	}
+	return p.parse()
+}
+
+type parser struct {
+	now    time.Time
+	opts   Options
+	tokens []string // as typed, for the title
+	words  []string // lower-cased, surrounding punctuation trimmed
+
+	result Result
+	title  []string
+
+	// What the date/time phrases resolved to; unset parts take defaults
+	date    *time.Time
+	hour    int
+	minute  int
+	hasTime bool
+	exact   *time.Time // "in 2 hours" fixes the whole timestamp
+	weekday *time.Weekday
+	// lastDate is the index just past the latest date phrase, so "tomorrow morning" can attach a part of day
+	lastDate int
+}
+
+// matcher tries to read a phrase at position i and returns how many words it used (0 for no match)
+type matcher func(p *parser, i int) int
+
+func (p *parser) parse() Result {
+	p.lastDate = -1
+	matchers := []matcher{(*parser).matchRelative, (*parser).matchRepeat, (*parser).matchDay, (*parser).matchDate, (*parser).matchTime}
+
+	for i := 0; i < len(p.tokens); {
+		tok, word := p.tokens[i], p.words[i]
+
+		switch {
+		case strings.HasPrefix(tok, "#") && len(tok) > 1:
+			p.result.Labels = append(p.result.Labels, strings.TrimRight(tok[1:], ".,;:!?"))
+			i++
+			continue
+		case word == "!pin" || word == "!pinned":
+			p.result.Pinned = true
+			i++
+			continue
+		case word == "!archive" || word == "!archived":
+			p.result.Archived = true
+			i++
+			continue
+		}
+
+		// "at 6pm", "on friday", "by tomorrow": the connector goes with the phrase it introduces
+		start := i
+		if isConnector(word) && i+1 < len(p.tokens) {
+			start = i + 1
+		}
+		n := 0
+		for _, m := range matchers {
+			if n = m(p, start); n > 0 {
+				break
+			}
+		}
+		if n == 0 {
+			p.title = append(p.title, tok)
+			i++
+			continue
+		}
+		p.result.Phrases = append(p.result.Phrases, strings.Join(p.tokens[i:start+n], " "))
+		i = start + n
+	}
+
+	p.result.Title = cleanTitle(p.title)
+	p.result.Reminder = p.resolve()
+	return p.result
+}
+
+// resolve combines the date and time phrases into the reminder time, or nil if there were none
+func (p *parser) resolve() *time.Time {
+	if p.exact != nil {
+		return p.exact
+	}
+	if p.date == nil && p.weekday == nil && !p.hasTime && p.result.RRule == "" {
+		return nil
+	}
+
+	hour, minute := p.opts.DefaultHour, p.opts.DefaultMinute
+	if p.hasTime {
+		hour, minute = p.hour, p.minute
+	}
+	day := p.now
+	if p.date != nil {
+		day = *p.date
+	} else if p.weekday != nil {
+		day = nextWeekday(p.now, *p.weekday, true)
+	}
+	y, m, d := day.Date()
+	t := time.Date(y, m, d, hour, minute, 0, 0, p.now.Location())
+
+	// A time on its own means its next occurrence
+	if p.date == nil && !t.After(p.now) {
+		if p.weekday != nil {
+			t = t.AddDate(0, 0, 7)
+		} else {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return &t
+}
+
+func (p *parser) setDate(t time.Time, end int) {
+	p.date = &t
+	p.lastDate = end
+}
+
+// matchRelative reads "in 20 minutes", "in an hour", "in 3 days", "in 2 weeks"
+func (p *parser) matchRelative(i int) int {
+	if p.exact != nil || p.date != nil || p.hasTime || p.word(i) != "in" {
+		return 0
+	}
+	n, ok := parseCount(p.word(i + 1))
+	if !ok {
+		return 0
+	}
+	t := p.now
+	switch strings.TrimSuffix(p.word(i+2), "s") {
+	case "min", "minute":
+		t = t.Add(time.Duration(n) * time.Minute)
+	case "hour", "hr":
+		t = t.Add(time.Duration(n) * time.Hour)
+	case "day":
+		t = t.AddDate(0, 0, n)
+	case "week":
+		t = t.AddDate(0, 0, 7*n)
+	case "month":
+		t = t.AddDate(0, n, 0)
+	default:
+		return 0
+	}
+	t = t.Truncate(time.Minute)
+	p.exact = &t
+	return 3
+}
+
+// matchRepeat reads "daily", "every day", "every weekday", "every friday", "every 2 weeks", ...
+func (p *parser) matchRepeat(i int) int {
+	if p.result.RRule != "" || p.exact != nil {
+		return 0
+	}
+	switch p.word(i) {
+	case "daily":
+		p.result.RRule = "FREQ=DAILY"
+		return 1
+	case "weekly":
+		p.result.RRule = "FREQ=WEEKLY"
+		return 1
+	case "monthly":
+		p.result.RRule = "FREQ=MONTHLY"
+		return 1
+	case "yearly", "annually":
+		p.result.RRule = "FREQ=YEARLY"
+		return 1
+	case "every":
+	default:
+		return 0
+	}
+
+	next := p.word(i + 1)
+	if wd, ok := weekdayNames[next]; ok {
+		p.result.RRule = "FREQ=WEEKLY;BYDAY=" + icalWeekday(wd)
+		p.weekday = &wd
+		return 2
+	}
+	if next == "weekday" || next == "weekdays" {
+		p.result.RRule = "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+		return 2
+	}
+
+	interval, used := 1, 1
+	if n, ok := parseCount(next); ok {
+		interval, used = n, 2
+	}
+	var freq string
+	switch strings.TrimSuffix(p.word(i+used), "s") {
+	case "day":
+		freq = "DAILY"
+	case "week":
+		freq = "WEEKLY"
+	case "month":
+		freq = "MONTHLY"
+	case "year":
+		freq = "YEARLY"
+	default:
+		return 0
+	}
+	p.result.RRule = "FREQ=" + freq
+	if interval > 1 {
+		p.result.RRule += ";INTERVAL=" + strconv.Itoa(interval)
+	}
+	return used + 1
+}
+
+// matchDay reads today, tonight, tomorrow, the day after tomorrow, weekdays and next week/month
+func (p *parser) matchDay(i int) int {
+	if p.date != nil || p.exact != nil {
+		return 0
+	}
+	today := p.now
+	switch w := p.word(i); w {
+	case "today":
+		p.setDate(today, i+1)
+		return 1
+	case "tonight":
+		p.setDate(today, i+1)
+		if !p.hasTime {
+			p.hour, p.minute, p.hasTime = tonightHour, 0, true
+		}
+		return 1
+	case "tomorrow", "tmr", "tmrw":
+		p.setDate(today.AddDate(0, 0, 1), i+1)
+		return 1
+	case "day", "the":
+		j := i
+		if w == "the" {
+			j++
+		}
+		if p.word(j) == "day" && p.word(j+1) == "after" && p.word(j+2) == "tomorrow" {
+			p.setDate(today.AddDate(0, 0, 2), j+3)
+			return j + 3 - i
+		}
+		return 0
+	case "next", "this":
+		switch p.word(i + 1) {
+		case "week":
+			if w == "this" {
+				return 0
+			}
+			p.setDate(nextWeekday(today, p.opts.WeekStart, false), i+2)
+			return 2
+		case "month":
+			if w == "this" {
+				return 0
+			}
+			y, m, _ := today.Date()
+			p.setDate(time.Date(y, m+1, 1, 0, 0, 0, 0, today.Location()), i+2)
+			return 2
+		}
+		if wd, ok := weekdayNames[p.word(i+1)]; ok {
+			p.setDate(nextWeekday(today, wd, w == "this"), i+2)
+			return 2
+		}
+		return 0
+	}
+	// A bare weekday is its next occurrence, which resolve works out once the time is known
+	if wd, ok := weekdayNames[p.word(i)]; ok && p.weekday == nil {
+		p.weekday = &wd
+		p.lastDate = i + 1
+		return 1
+	}
+	return 0
+}
+
+// matchDate reads 2025-04-03, "april 3", "apr 3rd", "3 april" (each with an optional year) and "the 21st"
+func (p *parser) matchDate(i int) int {
+	if p.date != nil || p.exact != nil {
+		return 0
+	}
+	loc := p.now.Location()
+	if t, err := time.ParseInLocation("2006-01-02", p.word(i), loc); err == nil {
+		p.setDate(t, i+1)
+		return 1
+	}
+
+	// "the 1st" is the next time that day of the month comes round
+	if p.word(i) == "the" && hasOrdinalSuffix(p.word(i+1)) {
+		d, ok := parseDayOfMonth(p.word(i + 1))
+		if !ok {
+			return 0
+		}
+		y, m, today := p.now.Date()
+		if d < today {
+			m++
+		}
+		for time.Date(y, m, d, 0, 0, 0, 0, loc).Day() != d {
+			m++ // skip months too short for the day
+		}
+		p.setDate(time.Date(y, m, d, 0, 0, 0, 0, loc), i+2)
+		return 2
+	}
+
+	var (
+		month time.Month
+		day   int
+		used  int
+	)
+	if m, ok := monthNames[p.word(i)]; ok {
+		if d, ok := parseDayOfMonth(p.word(i + 1)); ok {
+			month, day, used = m, d, 2
+		}
+	} else if d, ok := parseDayOfMonth(p.word(i)); ok {
+		if m, ok := monthNames[p.word(i+1)]; ok {
+			month, day, used = m, d, 2
+		}
+	}
+	if used == 0 {
+		return 0
+	}
+
+	year, explicitYear := p.now.Year(), false
+	if y, err := strconv.Atoi(p.word(i + used)); err == nil && y >= 1970 && y <= 9999 {
+		year, explicitYear = y, true
+		used++
+	}
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Month() != month {
+		return 0 // e.g. april 31
+	}
+	y, m, d := p.now.Date()
+	if !explicitYear && t.Before(time.Date(y, m, d, 0, 0, 0, 0, loc)) {
+		t = t.AddDate(1, 0, 0)
+	}
+	p.setDate(t, i+used)
+	return used
+}
+
+// matchTime reads 6pm, 6:30 pm, 18:00, noon, midnight, "at 6", and morning/afternoon/evening
+// right after a day ("tomorrow morning")
+func (p *parser) matchTime(i int) int {
+	if p.hasTime || p.exact != nil {
+		return 0
+	}
+	w := p.word(i)
+	switch w {
+	case "noon", "midday":
+		p.hour, p.minute, p.hasTime = 12, 0, true
+		return 1
+	case "midnight":
+		p.hour, p.minute, p.hasTime = 0, 0, true
+		return 1
+	case "morning", "afternoon", "evening", "night":
+		if p.lastDate != i {
+			return 0
+		}
+		p.hour = map[string]int{"morning": morningHour, "afternoon": afternoonHour, "evening": eveningHour, "night": tonightHour}[w]
+		p.minute, p.hasTime = 0, true
+		return 1
+	}
+
+	clock, used := w, 1
+	if suffix := p.word(i + 1); suffix == "am" || suffix == "pm" {
+		clock += suffix
+		used++
+	}
+	hour, minute, ok := parseClock(clock)
+	if !ok {
+		// A bare hour ("at 6") only counts after "at"; hours before 8 are assumed to be evening
+		n, err := strconv.Atoi(w)
+		if err != nil || i == 0 || p.word(i-1) != "at" || n < 1 || n > 12 {
+			return 0
+		}
+		hour, minute, used = n, 0, 1
+		if n < 8 {
+			hour += 12
+		}
+	}
+	p.hour, p.minute, p.hasTime = hour, minute, true
+	return used
+}
+
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.words) {
+		return ""
+	}
+	return p.words[i]
+}
+
+// parseClock reads 6pm, 6:30pm, 12am and 24-hour 18:00
+func parseClock(s string) (hour, minute int, ok bool) {
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		suffix, s = s[len(s)-2:], s[:len(s)-2]
+	}
+	hs, ms, hasMinutes := strings.Cut(s, ":")
+	if !hasMinutes && suffix == "" {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, 0, false
+	}
+	if hasMinutes {
+		if len(ms) != 2 {
+			return 0, 0, false
+		}
+		if minute, err = strconv.Atoi(ms); err != nil || minute > 59 {
+			return 0, 0, false
+		}
+	}
+	switch {
+	case suffix == "" && hour >= 0 && hour <= 23:
+	case suffix != "" && hour >= 1 && hour <= 12:
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// parseCount reads a small positive number, in digits or words ("a", "an", "two", "other")
+func parseCount(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n, true
+	}
+	n, ok := map[string]int{"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "ten": 10, "other": 2}[s]
+	return n, ok
+}
+
+func hasOrdinalSuffix(s string) bool {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDayOfMonth reads 3, 3rd, 21st
+func parseDayOfMonth(s string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	d, err := strconv.Atoi(s)
+	return d, err == nil && d >= 1 && d <= 31
+}
+
+// nextWeekday returns the next day falling on wd after from; today counts when includeToday is set
+func nextWeekday(from time.Time, wd time.Weekday, includeToday bool) time.Time {
+	days := (int(wd) - int(from.Weekday()) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return from.AddDate(0, 0, days)
+}
+
+func icalWeekday(wd time.Weekday) string {
+	return strings.ToUpper(wd.String()[:2])
+}
+
+func isConnector(w string) bool {
+	return w == "at" || w == "on" || w == "by" || w == "due"
+}
+
+// normalize lower-cases a word and trims the punctuation around it, keeping the '!' of flags
+func normalize(tok string) string {
+	w := strings.TrimFunc(strings.ToLower(tok), unicode.IsPunct)
+	if strings.HasPrefix(tok, "!") {
+		w = "!" + w
+	}
+	return w
+}
+
+// cleanTitle joins the words left over, dropping connectors stranded at the end ("Call mom at")
+func cleanTitle(words []string) string {
+	for len(words) > 0 && isConnector(normalize(words[len(words)-1])) {
+		words = words[:len(words)-1]
+	}
+	return strings.TrimSpace(strings.TrimRight(strings.Join(words, " "), " ,;:-"))
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday,
+}
+
+var monthNames = func() map[string]time.Month {
+	names := make(map[string]time.Month)
+	for m := time.January; m <= time.December; m++ {
+		full := strings.ToLower(m.String())
+		names[full] = m
+		names[full[:3]] = m
+	}
+	names["sept"] = time.September
+	return names
+}()
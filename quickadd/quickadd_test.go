@@ -0,0 +1,105 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	// A Wednesday, four days before the switch to summer time
+	now := time.Date(2025, 3, 26, 10, 30, 0, 0, berlin)
+	opts := Options{DefaultHour: 9, WeekStart: time.Monday}
+	at := func(month time.Month, day, hour, minute int) *time.Time {
+		t := time.Date(2025, month, day, hour, minute, 0, 0, berlin)
+		return &t
+	}
+
+	tests := []struct {
+		text string
+		want Result
+	}{
+		{"Nothing to see here", Result{Title: "Nothing to see here"}},
+		{"Buy milk tomorrow 6pm #groceries !pin", Result{Title: "Buy milk", Labels: []string{"groceries"}, Pinned: true, Reminder: at(3, 27, 18, 0)}},
+		{"File taxes !archive", Result{Title: "File taxes", Archived: true}},
+		{"Call Sunday", Result{Title: "Call", Reminder: at(3, 30, 9, 0)}},
+		{"Call mom on sunday at 8am", Result{Title: "Call mom", Reminder: at(3, 30, 8, 0)}},
+		// Today's default time has passed, so a bare weekday naming today means next week
+		{"Call mom wednesday", Result{Title: "Call mom", Reminder: at(4, 2, 9, 0)}},
+		{"Report next friday", Result{Title: "Report", Reminder: at(3, 28, 9, 0)}},
+		{"Review next week", Result{Title: "Review", Reminder: at(3, 31, 9, 0)}},
+		{"Plan the day after tomorrow morning", Result{Title: "Plan", Reminder: at(3, 28, 9, 0)}},
+		{"Party tonight", Result{Title: "Party", Reminder: at(3, 26, 20, 0)}},
+		{"Lunch at noon", Result{Title: "Lunch", Reminder: at(3, 26, 12, 0)}},
+		{"Meeting 18:00", Result{Title: "Meeting", Reminder: at(3, 26, 18, 0)}},
+		{"Call at 6", Result{Title: "Call", Reminder: at(3, 26, 18, 0)}},
+		{"Breakfast 8:15am", Result{Title: "Breakfast", Reminder: at(3, 27, 8, 15)}},
+		{"Ping in 20 minutes", Result{Title: "Ping", Reminder: at(3, 26, 10, 50)}},
+		{"Pay rent the 1st", Result{Title: "Pay rent", Reminder: at(4, 1, 9, 0)}},
+		{"Dentist april 3rd", Result{Title: "Dentist", Reminder: at(4, 3, 9, 0)}},
+		{"Dentist on 3 april 2026", Result{Title: "Dentist", Reminder: func() *time.Time { t := time.Date(2026, 4, 3, 9, 0, 0, 0, berlin); return &t }()}},
+		{"Anniversary march 1", Result{Title: "Anniversary", Reminder: func() *time.Time { t := time.Date(2026, 3, 1, 9, 0, 0, 0, berlin); return &t }()}},
+		{"Dentist april 31", Result{Title: "Dentist april 31"}},
+		{"Invoice by 2025-04-10", Result{Title: "Invoice", Reminder: at(4, 10, 9, 0)}},
+		{"Water plants daily", Result{Title: "Water plants", Reminder: at(3, 27, 9, 0), RRule: "FREQ=DAILY"}},
+		{"Gym every 2 weeks", Result{Title: "Gym", Reminder: at(3, 27, 9, 0), RRule: "FREQ=WEEKLY;INTERVAL=2"}},
+		{"Bins every monday", Result{Title: "Bins", Reminder: at(3, 31, 9, 0), RRule: "FREQ=WEEKLY;BYDAY=MO"}},
+		{"Standup every weekday at 9:30", Result{Title: "Standup", Reminder: at(3, 27, 9, 30), RRule: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := Parse(tt.text, now, opts)
+			if tt.want.Labels == nil {
+				tt.want.Labels = []string{}
+			}
+			if got.Title != tt.want.Title {
+				t.Errorf("Title = %q, want %q", got.Title, tt.want.Title)
+			}
+			if !reflect.DeepEqual(got.Labels, tt.want.Labels) {
+				t.Errorf("Labels = %v, want %v", got.Labels, tt.want.Labels)
+			}
+			if got.Pinned != tt.want.Pinned || got.Archived != tt.want.Archived {
+				t.Errorf("Pinned, Archived = %v, %v, want %v, %v", got.Pinned, got.Archived, tt.want.Pinned, tt.want.Archived)
+			}
+			if got.RRule != tt.want.RRule {
+				t.Errorf("RRule = %q, want %q", got.RRule, tt.want.RRule)
+			}
+			switch {
+			case got.Reminder == nil && tt.want.Reminder == nil:
+			case got.Reminder == nil || tt.want.Reminder == nil || !got.Reminder.Equal(*tt.want.Reminder):
+				t.Errorf("Reminder = %v, want %v", got.Reminder, tt.want.Reminder)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in           string
+		hour, minute int
+		ok           bool
+	}{
+		{"6pm", 18, 0, true},
+		{"6:30pm", 18, 30, true},
+		{"12am", 0, 0, true},
+		{"12pm", 12, 0, true},
+		{"18:00", 18, 0, true},
+		{"0:05", 0, 5, true},
+		{"6", 0, 0, false},
+		{"13pm", 0, 0, false},
+		{"24:00", 0, 0, false},
+		{"6:5", 0, 0, false},
+		{"6:60", 0, 0, false},
+	}
+	for _, tt := range tests {
+		hour, minute, ok := parseClock(tt.in)
+		if hour != tt.hour || minute != tt.minute || ok != tt.ok {
+			t.Errorf("parseClock(%q) = %d, %d, %v, want %d, %d, %v", tt.in, hour, minute, ok, tt.hour, tt.minute, tt.ok)
+		}
+	}
+}
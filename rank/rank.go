@@ -0,0 +1,92 @@
+// Package rank generates lexicographic sort keys. Any two keys have room for another key
+// between them, so an item can be moved by rewriting only its own key. Keys use the digits
+// 0-9a-z and never end in '0'; compare them byte-wise (COLLATE "C" in Postgres).
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+var ErrInvalidRange = errors.New("rank: lower bound must sort before upper bound")
+
+// Between returns a key that sorts after a and before b. An empty a means "before
+// everything" and an empty b means "after everything".
+func Between(a, b string) (string, error) {
+	if b != "" && a >= b {
+		return "", ErrInvalidRange
+	}
+	if a == "" && b == "" {
+		return digits[base/2 : base/2+1], nil
+	}
+
+	// Appending and prepending step by one digit instead of halving, so keys
+	// pushed repeatedly onto either end of a list grow slowly
+	prepend, appending := a == "", b == ""
+
+	var out strings.Builder
+	bounded := !appending
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(a) {
+			lo = strings.IndexByte(digits, a[i])
+		}
+		hi := base
+		if bounded {
+			if i >= len(b) {
+				return "", ErrInvalidRange
+			}
+			hi = strings.IndexByte(digits, b[i])
+		}
+		if lo < 0 || hi < 0 {
+			return "", ErrInvalidRange
+		}
+
+		switch {
+		case !bounded && prepend:
+			out.WriteByte(digits[base-1])
+			return out.String(), nil
+		case !bounded && appending && lo < base-1:
+			out.WriteByte(digits[lo+1])
+			return out.String(), nil
+		case bounded && prepend && hi > 1:
+			out.WriteByte(digits[hi-1])
+			return out.String(), nil
+		case hi-lo > 1:
+			out.WriteByte(digits[(lo+hi)/2])
+			return out.String(), nil
+		case hi-lo == 1:
+			// Already below b from here on
+			out.WriteByte(digits[lo])
+			bounded = false
+		default:
+			out.WriteByte(digits[lo])
+		}
+	}
+}
+
+// Sequence returns n evenly spaced, increasing keys of equal length, for (re)numbering a whole list
+func Sequence(n int) []string {
+	width, space := 1, base
+	for space <= n {
+		width++
+		space *= base
+	}
+
+	keys := make([]string, n)
+	step := space / (n + 1)
+	for i := range keys {
+		v := (i + 1) * step
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = digits[v%base]
+			v /= base
+		}
+		keys[i] = strings.TrimRight(string(key), "0")
+	}
+	return keys
+}
@@ -0,0 +1,93 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventNoteCreated  = "note.created"
+	EventNoteUpdated  = "note.updated"
+	EventNoteArchived = "note.archived"
+	EventNoteDeleted  = "note.deleted"
+	EventNoteRestored = "note.restored"
+
+	EventNotification = "notification.created"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before events are dropped for it
+const subscriberBuffer = 32
+
+// Event is a change pushed to every connected client of the users in Recipients
+type Event struct {
+	Type       string      `json:"type"`
+	NoteID     uuid.UUID   `json:"note_id"`
+	Actor      string      `json:"actor"`
+	Note       interface{} `json:"note,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	At         time.Time   `json:"at"`
+	Recipients []string    `json:"-"`
+}
+
+// Broker fans note events out to subscribed clients. Hub is the in-process implementation;
+// a multi-instance deployment can swap in one backed by Postgres LISTEN/NOTIFY.
+type Broker interface {
+	Publish(event Event)
+	Subscribe(userID string) (<-chan Event, func())
+}
+
+// Hub delivers events to subscribers connected to this process
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[string]map[chan Event]struct{})}
+}
+
+// Publish sends the event to every subscriber of each recipient without blocking
+func (h *Hub) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, userID := range event.Recipients {
+		for ch := range h.subscribers[userID] {
+			select {
+			case ch <- event:
+			default:
+				// Client isn't keeping up; it will resync on its next full fetch
+			}
+		}
+	}
+}
+
+// Subscribe registers a client for a user's events. The returned function unsubscribes and closes the channel.
+func (h *Hub) Subscribe(userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userID], ch)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"database/sql"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CalendarFeedRepository struct {
+	db *gorm.DB
+}
+
+func NewCalendarFeedRepository(db *gorm.DB) *CalendarFeedRepository {
+	return &CalendarFeedRepository{db: db}
+}
+
+// GetByUser returns the user's feed, or gorm.ErrRecordNotFound if they haven't created one
+func (r *CalendarFeedRepository) GetByUser(userID string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := r.db.Where("user_id = ?", userID).First(&feed).Error
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *CalendarFeedRepository) GetByTokenHash(tokenHash string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := r.db.Where("token_hash = ?", tokenHash).First(&feed).Error
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// SetToken creates the user's feed or replaces its token, invalidating the previous one
+func (r *CalendarFeedRepository) SetToken(userID, tokenHash string) (*models.CalendarFeed, error) {
+	feed := models.CalendarFeed{ID: uuid.New(), UserID: userID, TokenHash: tokenHash}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "updated_at"}),
+	}).Create(&feed).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByUser(userID)
+}
+
+func (r *CalendarFeedRepository) Delete(userID string) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error
+}
+
+// GetReminders returns the live reminders on every live note the user owns or collaborates on,
+// with their note loaded
+func (r *CalendarFeedRepository) GetReminders(userID string) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Preload("Note").
+		Where("note_id IN (?)", r.db.Model(&models.Note{}).Select("notes.id").Where(accessibleByUser, sql.Named("user", userID))).
+		Order("time").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// GetOverrides returns the overrides of the given reminders, grouped by reminder
+func (r *CalendarFeedRepository) GetOverrides(reminderIDs []uuid.UUID) (map[uuid.UUID][]models.ReminderOverride, error) {
+	byReminder := make(map[uuid.UUID][]models.ReminderOverride)
+	if len(reminderIDs) == 0 {
+		return byReminder, nil
+	}
+	var overrides []models.ReminderOverride
+	if err := r.db.Where("reminder_id IN ?", reminderIDs).Order("original_time").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		byReminder[o.ReminderID] = append(byReminder[o.ReminderID], o)
+	}
+	return byReminder, nil
+}
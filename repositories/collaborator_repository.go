@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"time"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollaboratorRepository struct {
+	db *gorm.DB
+}
+
+func NewCollaboratorRepository(db *gorm.DB) *CollaboratorRepository {
+	return &CollaboratorRepository{db: db}
+}
+
+// Create a new collaborator, clearing an earlier revocation of their access
+func (r *CollaboratorRepository) Create(collaborator *models.NoteCollaborator) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("note_id = ? AND user_id = ?", collaborator.NoteID, collaborator.UserID).Delete(&models.NoteRevocation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(collaborator).Error
+	})
+}
+
+// Get all collaborators of a note
+func (r *CollaboratorRepository) GetAllByNote(noteID uuid.UUID) ([]models.NoteCollaborator, error) {
+	var collaborators []models.NoteCollaborator
+	err := r.db.Where("note_id = ?", noteID).Order("created_at").Find(&collaborators).Error
+	return collaborators, err
+}
+
+// Get a user's collaborator entry on a note
+func (r *CollaboratorRepository) GetByNoteAndUser(noteID uuid.UUID, userID string) (*models.NoteCollaborator, error) {
+	var collaborator models.NoteCollaborator
+	err := r.db.Where("note_id = ? AND user_id = ?", noteID, userID).First(&collaborator).Error
+	if err != nil {
+		return nil, err
+	}
+	return &collaborator, nil
+}
+
+// RoleFor returns the role a user holds on a note, or an empty string if they have no access
+func (r *CollaboratorRepository) RoleFor(note *models.Note, userID string) (string, error) {
+	if note.CreatedBy == userID {
+		return models.NoteRoleOwner, nil
+	}
+	collaborator, err := r.GetByNoteAndUser(note.ID, userID)
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return collaborator.Role, nil
+}
+
+// Update a collaborator
+func (r *CollaboratorRepository) Update(collaborator *models.NoteCollaborator) error {
+	return r.db.Save(collaborator).Error
+}
+
+// Delete revokes a collaborator's access, remembering when for sync
+func (r *CollaboratorRepository) Delete(collaborator *models.NoteCollaborator) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.NoteCollaborator{}, "id = ?", collaborator.ID).Error; err != nil {
+			return err
+		}
+		revocation := models.NoteRevocation{NoteID: collaborator.NoteID, UserID: collaborator.UserID, RevokedAt: time.Now()}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&revocation).Error
+	})
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"strings"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LabelRepository struct {
+	db *gorm.DB
+}
+
+func NewLabelRepository(db *gorm.DB) *LabelRepository {
+	return &LabelRepository{db: db}
+}
+
+// Create a new label
+func (r *LabelRepository) Create(label *models.Label) error {
+	return r.db.Create(label).Error
+}
+
+// Get all labels owned by a user, ordered by name
+func (r *LabelRepository) GetAllByUser(userID string) ([]models.Label, error) {
+	var labels []models.Label
+	err := r.db.Where("created_by = ?", userID).Order("name").Find(&labels).Error
+	return labels, err
+}
+
+// Get label by ID (UUID)
+func (r *LabelRepository) GetByID(id uuid.UUID) (*models.Label, error) {
+	var label models.Label
+	err := r.db.First(&label, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// Get a user's label by name (case-insensitive)
+func (r *LabelRepository) GetByName(userID, name string) (*models.Label, error) {
+	var label models.Label
+	err := r.db.Where("created_by = ? AND LOWER(name) = LOWER(?)", userID, name).First(&label).Error
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// FindOrCreateByNames resolves label names for a user, creating any that don't exist yet
+func (r *LabelRepository) FindOrCreateByNames(userID string, names []string) ([]models.Label, error) {
+	var labels []models.Label
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		label, err := r.GetByName(userID, name)
+		if err == gorm.ErrRecordNotFound {
+			label = &models.Label{
+				ID:        uuid.New(),
+				Name:      name,
+				CreatedBy: userID,
+			}
+			err = r.Create(label)
+		}
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, *label)
+	}
+	return labels, nil
+}
+
+// Update a label
+func (r *LabelRepository) Update(label *models.Label) error {
+	return r.db.Save(label).Error
+}
+
+// Delete a label and detach it from every note
+func (r *LabelRepository) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM note_labels WHERE label_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Label{}, "id = ?", id).Error
+	})
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NoteSortUpdatedAt = "updated_at"
+	NoteSortCreatedAt = "created_at"
+	NoteSortTitle     = "title"
+	NoteSortRank      = "rank"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NoteListOptions describes the filters, sort order and page requested from NoteRepository.List
+type NoteListOptions struct {
+	Label         string
+	IsPinned      *bool
+	IsArchived    *bool
+	IsChecklist   *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	Sort        string
+	Descending  bool
+	PinnedFirst bool
+
+	Limit  int
+	Cursor *NoteCursor
+}
+
+// NoteCursor marks the last note of a page. It records the sort it was issued for so it
+// can't be replayed against a different ordering.
+type NoteCursor struct {
+	Sort        string    `json:"s"`
+	Descending  bool      `json:"d"`
+	PinnedFirst bool      `json:"p"`
+	IsPinned    bool      `json:"ip"`
+	Value       string    `json:"v"`
+	ID          uuid.UUID `json:"id"`
+}
+
+// Encode returns the opaque string handed to clients
+func (c NoteCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeNoteCursor parses a cursor previously produced by Encode
+func DecodeNoteCursor(s string) (*NoteCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c NoteCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// matches reports whether the cursor was issued for the given sort options
+func (c NoteCursor) matches(opts NoteListOptions) bool {
+	return c.Sort == opts.Sort && c.Descending == opts.Descending && c.PinnedFirst == opts.PinnedFirst
+}
+
+// sortValue reads the column the list is sorted by from a cursor, in the type the column expects
+func (c NoteCursor) sortValue() (interface{}, error) {
+	if c.Sort == NoteSortTitle || c.Sort == NoteSortRank {
+		return c.Value, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
@@ -1,11 +1,58 @@
 package repositories
 
 import (
-	"fmt"
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+	"time"
 	"todo-backend/models"
+	"todo-backend/rank"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NoteSearchResult is a single full-text search hit with its rank and highlighted snippets
+type NoteSearchResult struct {
+	NoteID             uuid.UUID
+	Rank               float64
+	TitleSnippet       string
+	DescriptionSnippet string
+	ChecklistSnippet   string
+}
+
+// ts_headline marks matches with these control characters rather than HTML, since the text
+// around them isn't escaped; highlightSnippet turns them into <mark> once it is
+const (
+	highlightStart   = "\x02"
+	highlightStop    = "\x03"
+	highlightOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"`
+)
+
+var highlightMarks = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// highlightSnippet HTML-escapes a ts_headline snippet and wraps its matches in <mark>
+func highlightSnippet(s string) string {
+	return highlightMarks.Replace(html.EscapeString(s))
+}
+
+// noteSearchVector must match the expression of idx_notes_search so the GIN index is used
+const noteSearchVector = `to_tsvector('english', coalesce(notes.title, '') || ' ' || coalesce(notes.description, ''))`
+
+var ErrVersionConflict = errors.New("note version conflict")
+
+var ErrInvalidOrder = errors.New("order must list every checklist item exactly once")
+
+var ErrInvalidMove = errors.New("neighbours must be adjacent items of the same list")
+
+var ErrInvalidParent = errors.New("parent must be another item of the same checklist and not one of its sub-tasks")
+
+// Ranks are compared byte-wise regardless of the database's default collation
+const (
+	noteRankOrder  = `notes.rank COLLATE "C"`
+	checklistOrder = `checklist_items.rank COLLATE "C", checklist_items.created_at`
 )
 
 type NoteRepository struct {
@@ -16,21 +63,266 @@ func NewNoteRepository(db *gorm.DB) *NoteRepository {
 	return &NoteRepository{db: db}
 }
 
-// Create a new note
+// Create a new note. Unranked notes are placed at the top of the owner's section.
 func (r *NoteRepository) Create(note *models.Note) error {
-	fmt.Printf("Saving to DB: %+v\n", note)
-	err := r.db.Create(note).Error
-	fmt.Printf("Saved Note with ID: %s, Error: %v\n", note.ID, err)
-	return err
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if note.Rank == "" {
+			if err := r.placeAtTop(tx, note); err != nil {
+				return err
+			}
+		}
+		return tx.Create(note).Error
+	})
 }
 
-// Get all notes created by a specific user (UUID)
+// Get all notes created by a specific user (UUID), pinned first and then in rank order
 func (r *NoteRepository) GetAllByUser(userID string) ([]models.Note, error) {
 	var notes []models.Note
-	err := r.db.Where("created_by = ?", userID).Find(&notes).Error
+	err := r.db.Where("created_by = ?", userID).Order("is_pinned DESC").Order(noteRankOrder).Find(&notes).Error
 	return notes, err
 }
 
+// MoveNote re-ranks a note between two neighbours from its owner's pinned or unpinned section.
+// Moving counts as an edit, so the version and UpdatedAt change and sync clients pick it up.
+func (r *NoteRepository) MoveNote(note *models.Note, afterID, beforeID *uuid.UUID, updatedBy string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockNoteSections(tx, note.CreatedBy); err != nil {
+			return err
+		}
+		section := tx.Model(&models.Note{}).Where("created_by = ? AND is_pinned = ? AND id <> ?", note.CreatedBy, note.IsPinned, note.ID)
+		newRank, err := rankBetween(section, "notes.id", noteRankOrder, afterID, beforeID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(note).
+			Clauses(clause.Returning{}).
+			Updates(map[string]interface{}{
+				"rank":       newRank,
+				"version":    gorm.Expr("version + 1"),
+				"updated_by": updatedBy,
+				"updated_at": time.Now(),
+			}).Error
+	})
+}
+
+// placeAtTop ranks a note above the others in its owner's pinned or unpinned section
+func (r *NoteRepository) placeAtTop(tx *gorm.DB, note *models.Note) error {
+	if err := lockNoteSections(tx, note.CreatedBy); err != nil {
+		return err
+	}
+	first, err := r.firstNoteRank(tx, note.CreatedBy, note.IsPinned)
+	if err != nil {
+		return err
+	}
+	note.Rank, err = rank.Between("", first)
+	return err
+}
+
+// placeIfRepinned moves a note that is being pinned or unpinned to the top of its new section,
+// since its rank only orders it within the old one
+func (r *NoteRepository) placeIfRepinned(tx *gorm.DB, note *models.Note) error {
+	var pinned []bool
+	if err := tx.Model(&models.Note{}).Where("id = ?", note.ID).Pluck("is_pinned", &pinned).Error; err != nil {
+		return err
+	}
+	if len(pinned) == 0 || pinned[0] == note.IsPinned {
+		return nil
+	}
+	return r.placeAtTop(tx, note)
+}
+
+// lockNoteSections serializes rank changes among a user's notes until the transaction ends, so
+// two notes can't be given the same rank. There may be no row to lock, so it's an advisory lock.
+func lockNoteSections(tx *gorm.DB, userID string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "note-ranks:"+userID).Error
+}
+
+// firstNoteRank returns the lowest rank in a user's pinned or unpinned section, or "" if it is empty
+func (r *NoteRepository) firstNoteRank(tx *gorm.DB, userID string, pinned bool) (string, error) {
+	var ranks []string
+	err := tx.Model(&models.Note{}).
+		Where("created_by = ? AND is_pinned = ?", userID, pinned).
+		Order(noteRankOrder).
+		Limit(1).
+		Pluck("rank", &ranks).Error
+	if err != nil || len(ranks) == 0 {
+		return "", err
+	}
+	return ranks[0], nil
+}
+
+// accessibleByUser matches notes a user owns or collaborates on
+const accessibleByUser = "(notes.created_by = @user OR notes.id IN (SELECT note_id FROM note_collaborators WHERE user_id = @user))"
+
+// List returns one page of the notes a user owns or collaborates on matching opts, the total number of matches
+// across all pages, and the cursor for the next page (nil on the last page)
+func (r *NoteRepository) List(userID string, opts NoteListOptions) ([]models.Note, int64, *NoteCursor, error) {
+	switch opts.Sort {
+	case NoteSortCreatedAt, NoteSortTitle, NoteSortUpdatedAt:
+	default:
+		opts.Sort = NoteSortRank
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 50
+	}
+
+	query := r.db.Model(&models.Note{}).Where(accessibleByUser, sql.Named("user", userID))
+
+	if opts.Label != "" {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM note_labels JOIN labels ON labels.id = note_labels.label_id
+			WHERE note_labels.note_id = notes.id AND LOWER(labels.name) = LOWER(?))`, opts.Label)
+	}
+	if opts.IsPinned != nil {
+		query = query.Where("notes.is_pinned = ?", *opts.IsPinned)
+	}
+	if opts.IsArchived != nil {
+		query = query.Where("notes.is_archived = ?", *opts.IsArchived)
+	}
+	if opts.IsChecklist != nil {
+		query = query.Where("notes.is_checklist = ?", *opts.IsChecklist)
+	}
+	if opts.CreatedAfter != nil {
+		query = query.Where("notes.created_at >= ?", *opts.CreatedAfter)
+	}
+	if opts.CreatedBefore != nil {
+		query = query.Where("notes.created_at < ?", *opts.CreatedBefore)
+	}
+	if opts.UpdatedAfter != nil {
+		query = query.Where("notes.updated_at >= ?", *opts.UpdatedAfter)
+	}
+	if opts.UpdatedBefore != nil {
+		query = query.Where("notes.updated_at < ?", *opts.UpdatedBefore)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	sortColumn := "notes." + opts.Sort
+	if opts.Sort == NoteSortRank {
+		sortColumn = noteRankOrder
+	}
+	direction, op := "ASC", ">"
+	if opts.Descending {
+		direction, op = "DESC", "<"
+	}
+
+	if opts.Cursor != nil {
+		if !opts.Cursor.matches(opts) {
+			return nil, 0, nil, ErrInvalidCursor
+		}
+		value, err := opts.Cursor.sortValue()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		keyset := "(" + sortColumn + ", notes.id) " + op + " (?, ?)"
+		switch {
+		case !opts.PinnedFirst:
+			query = query.Where(keyset, value, opts.Cursor.ID)
+		case opts.Cursor.IsPinned:
+			query = query.Where("(notes.is_pinned = false OR (notes.is_pinned = true AND "+keyset+"))", value, opts.Cursor.ID)
+		default:
+			query = query.Where("notes.is_pinned = false AND "+keyset, value, opts.Cursor.ID)
+		}
+	}
+
+	if opts.PinnedFirst {
+		query = query.Order("notes.is_pinned DESC")
+	}
+	query = query.Order(sortColumn + " " + direction).Order("notes.id " + direction)
+
+	var notes []models.Note
+	if err := query.Limit(opts.Limit + 1).Find(&notes).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	var next *NoteCursor
+	if len(notes) > opts.Limit {
+		notes = notes[:opts.Limit]
+		last := notes[len(notes)-1]
+		next = &NoteCursor{
+			Sort:        opts.Sort,
+			Descending:  opts.Descending,
+			PinnedFirst: opts.PinnedFirst,
+			IsPinned:    last.IsPinned,
+			ID:          last.ID,
+		}
+		switch opts.Sort {
+		case NoteSortTitle:
+			next.Value = last.Title
+		case NoteSortRank:
+			next.Value = last.Rank
+		case NoteSortCreatedAt:
+			next.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		default:
+			next.Value = last.UpdatedAt.Format(time.RFC3339Nano)
+		}
+	}
+
+	return notes, total, next, nil
+}
+
+// EnsureSearchIndexes creates the GIN indexes backing full-text search
+func (r *NoteRepository) EnsureSearchIndexes() error {
+	stmts := []string{
+		`CREATE INDEX IF NOT EXISTS idx_notes_search ON notes USING GIN (` + noteSearchVector + `)`,
+		`CREATE INDEX IF NOT EXISTS idx_checklist_items_search ON checklist_items USING GIN (to_tsvector('english', coalesce(checklist_items.text, '')))`,
+	}
+	for _, stmt := range stmts {
+		if err := r.db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search runs a ranked full-text query over the titles, descriptions and checklist items of notes a user can access
+func (r *NoteRepository) Search(userID, query string, limit int) ([]NoteSearchResult, error) {
+	var results []NoteSearchResult
+	stmt := `
+WITH q AS (SELECT websearch_to_tsquery('english', @query) AS query),
+item_matches AS (
+	SELECT checklist_items.note_id,
+		MAX(ts_rank(to_tsvector('english', coalesce(checklist_items.text, '')), q.query)) AS rank,
+		string_agg(checklist_items.text, ' ... ') AS text
+	FROM checklist_items, q
+	WHERE checklist_items.deleted_at IS NULL
+		AND to_tsvector('english', coalesce(checklist_items.text, '')) @@ q.query
+	GROUP BY checklist_items.note_id
+)
+SELECT notes.id AS note_id,
+	ts_rank(
+		setweight(to_tsvector('english', coalesce(notes.title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(notes.description, '')), 'B'),
+		q.query
+	) + coalesce(item_matches.rank, 0) * 0.5 AS rank,
+	ts_headline('english', coalesce(notes.title, ''), q.query, @highlight || ', HighlightAll=true') AS title_snippet,
+	ts_headline('english', coalesce(notes.description, ''), q.query, @highlight || ', MaxFragments=2, MaxWords=20, MinWords=5') AS description_snippet,
+	ts_headline('english', coalesce(item_matches.text, ''), q.query, @highlight || ', MaxFragments=2, MaxWords=20, MinWords=5') AS checklist_snippet
+FROM notes
+CROSS JOIN q
+LEFT JOIN item_matches ON item_matches.note_id = notes.id
+WHERE ` + accessibleByUser + `
+	AND notes.deleted_at IS NULL
+	AND (` + noteSearchVector + ` @@ q.query OR item_matches.note_id IS NOT NULL)
+ORDER BY rank DESC, notes.updated_at DESC
+LIMIT @limit`
+	err := r.db.Raw(stmt, map[string]interface{}{
+		"query":     query,
+		"user":      userID,
+		"limit":     limit,
+		"highlight": highlightOptions,
+	}).Scan(&results).Error
+	for i := range results {
+		results[i].TitleSnippet = highlightSnippet(results[i].TitleSnippet)
+		results[i].DescriptionSnippet = highlightSnippet(results[i].DescriptionSnippet)
+		results[i].ChecklistSnippet = highlightSnippet(results[i].ChecklistSnippet)
+	}
+	return results, err
+}
+
 // Get note by ID (UUID)
 func (r *NoteRepository) GetByID(id uuid.UUID) (*models.Note, error) {
 	var note models.Note
@@ -41,14 +333,334 @@ func (r *NoteRepository) GetByID(id uuid.UUID) (*models.Note, error) {
 	return &note, nil
 }
 
-// Update a note
+// Update a note, bumping its version
 func (r *NoteRepository) Update(note *models.Note) error {
-	return r.db.Save(note).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.placeIfRepinned(tx, note); err != nil {
+			return err
+		}
+		note.Version++
+		return tx.Save(note).Error
+	})
+}
+
+// UpdateIfVersion saves a note only if the stored version still equals expected,
+// returning ErrVersionConflict when another write got there first
+func (r *NoteRepository) UpdateIfVersion(note *models.Note, expected int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.placeIfRepinned(tx, note); err != nil {
+			return err
+		}
+		note.Version = expected + 1
+		res := tx.Model(note).
+			Select("*").
+			Omit("id", "created_by", "created_at", "deleted_at", clause.Associations).
+			Where("version = ?", expected).
+			Updates(note)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return nil
+	})
 }
 
-// Delete a note (soft delete)
+// Delete a note (soft delete). Checklist items and reminders are trashed with the
+// same timestamp so Restore can bring back exactly what was deleted alongside the note.
 func (r *NoteRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Note{}, "id = ?", id).Error
+	return r.trash(id, nil)
+}
+
+// DeleteIfVersion trashes a note like Delete, but only if the stored version still equals
+// expected, returning ErrVersionConflict when another write got there first
+func (r *NoteRepository) DeleteIfVersion(id uuid.UUID, expected int) error {
+	return r.trash(id, &expected)
+}
+
+func (r *NoteRepository) trash(id uuid.UUID, expected *int) error {
+	now := time.Now().Truncate(time.Microsecond)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Note{}).Where("id = ?", id)
+		if expected != nil {
+			query = query.Where("version = ?", *expected)
+		}
+		res := query.Update("deleted_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if expected != nil && res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if err := tx.Model(&models.ChecklistItem{}).Where("note_id = ?", id).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Reminder{}).Where("note_id = ?", id).Update("deleted_at", now).Error
+	})
+}
+
+// ApplyRevision rolls a note's content and checklist back to a stored revision
+func (r *NoteRepository) ApplyRevision(rev *models.NoteRevision, updatedBy string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var note models.Note
+		if err := tx.First(&note, "id = ?", rev.NoteID).Error; err != nil {
+			return err
+		}
+		note.IsPinned = rev.IsPinned
+		if err := r.placeIfRepinned(tx, &note); err != nil {
+			return err
+		}
+		err := tx.Model(&models.Note{}).Where("id = ?", rev.NoteID).Updates(map[string]interface{}{
+			"title":        rev.Title,
+			"description":  rev.Description,
+			"is_pinned":    rev.IsPinned,
+			"is_archived":  rev.IsArchived,
+			"is_checklist": rev.IsChecklist,
+			"rank":         note.Rank,
+			"version":      gorm.Expr("version + 1"),
+			"updated_by":   updatedBy,
+			"updated_at":   time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// Items missing from the snapshot are soft-deleted so sync clients see them go;
+		// items in it are revived in place (keeping their IDs) or recreated if purged
+		keep := []uuid.UUID{uuid.Nil}
+		for _, snap := range rev.Checklist {
+			keep = append(keep, snap.ID)
+		}
+		if err := tx.Where("note_id = ? AND id NOT IN ?", rev.NoteID, keep).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		ranks := rank.Sequence(len(rev.Checklist))
+		for i, snap := range rev.Checklist {
+			var parentID *uuid.UUID
+			if snap.ParentID != uuid.Nil {
+				parentID = &snap.ParentID
+			}
+			res := tx.Unscoped().Model(&models.ChecklistItem{}).
+				Where("id = ? AND note_id = ?", snap.ID, rev.NoteID).
+				Updates(map[string]interface{}{
+					"parent_id":  parentID,
+					"text":       snap.Text,
+					"is_checked": snap.IsChecked,
+					"rank":       ranks[i],
+					"deleted_at": nil,
+					"updated_at": time.Now(),
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				continue
+			}
+			item := models.ChecklistItem{
+				ID:        snap.ID,
+				NoteID:    rev.NoteID,
+				ParentID:  parentID,
+				Text:      snap.Text,
+				IsChecked: snap.IsChecked,
+				Rank:      ranks[i],
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+		return settleChecklist(tx, rev.NoteID, nil)
+	})
+}
+
+// GetTrashByUser lists a user's soft-deleted notes, most recently deleted first
+func (r *NoteRepository) GetTrashByUser(userID string) ([]models.Note, error) {
+	var notes []models.Note
+	err := r.db.Unscoped().
+		Where("created_by = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&notes).Error
+	return notes, err
+}
+
+// GetTrashedByID loads a soft-deleted note
+func (r *NoteRepository) GetTrashedByID(id uuid.UUID) (*models.Note, error) {
+	var note models.Note
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&note, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// Restore brings a trashed note back along with the children deleted together with it
+func (r *NoteRepository) Restore(note *models.Note) error {
+	deletedAt := note.DeletedAt.Time
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.ChecklistItem{}).
+			Where("note_id = ? AND deleted_at = ?", note.ID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Reminder{}).
+			Where("note_id = ? AND deleted_at = ?", note.ID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.Note{}).Where("id = ?", note.ID).Update("deleted_at", nil).Error
+	})
+}
+
+// Purge permanently removes a note and everything attached to it. Devices that synced before
+// the note was trashed may not have seen it go yet, so its owner and collaborators keep a
+// revocation for it until the trash retention has passed.
+func (r *NoteRepository) Purge(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var userIDs []string
+		if err := tx.Unscoped().Model(&models.Note{}).Where("id = ?", id).Pluck("created_by", &userIDs).Error; err != nil {
+			return err
+		}
+		var collaboratorIDs []string
+		if err := tx.Model(&models.NoteCollaborator{}).Where("note_id = ?", id).Pluck("user_id", &collaboratorIDs).Error; err != nil {
+			return err
+		}
+		if err := purgeNotes(tx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, userID := range append(userIDs, collaboratorIDs...) {
+			revocation := models.NoteRevocation{NoteID: id, UserID: userID, RevokedAt: now}
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&revocation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeTrashedBefore permanently removes notes that have been in the trash since before cutoff
+func (r *NoteRepository) PurgeTrashedBefore(cutoff time.Time) (int, error) {
+	var ids []uuid.UUID
+	err := r.db.Unscoped().Model(&models.Note{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if len(ids) > 0 {
+			if err := purgeNotes(tx, ids); err != nil {
+				return err
+			}
+		}
+		// Checklist items and reminders removed while editing live notes, and revoked shares, are tombstones for sync
+		if err := tx.Where("revoked_at < ?", cutoff).Delete(&models.NoteRevocation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("reminder_id IN (?)", tx.Unscoped().Model(&models.Reminder{}).Select("id").Where("deleted_at < ?", cutoff)).Delete(&models.ReminderOverride{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Reminder{}).Error
+	})
+	return len(ids), err
+}
+
+func purgeNotes(tx *gorm.DB, ids []uuid.UUID) error {
+	if err := tx.Unscoped().Where("note_id IN ?", ids).Delete(&models.ChecklistItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("reminder_id IN (?)", tx.Unscoped().Model(&models.Reminder{}).Select("id").Where("note_id IN ?", ids)).Delete(&models.ReminderOverride{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("note_id IN ?", ids).Delete(&models.Reminder{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("DELETE FROM note_labels WHERE note_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN ?", ids).Delete(&models.NoteRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN ?", ids).Delete(&models.NoteCollaborator{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN ?", ids).Delete(&models.NoteRevocation{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN ?", ids).Delete(&models.NoteShareLink{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Note{}).Error
+}
+
+// EnsureRanks ranks notes and checklist items stored before ranks existed, keeping the order
+// they used to be listed in: checklist items by their old position column (dropped once
+// converted) or creation time, notes by most recently updated.
+func (r *NoteRepository) EnsureRanks() error {
+	itemOrder := "created_at"
+	hasPosition := r.db.Migrator().HasColumn(&models.ChecklistItem{}, "position")
+	if hasPosition {
+		itemOrder = "position, created_at"
+	}
+
+	var noteIDs []uuid.UUID
+	if err := r.db.Unscoped().Model(&models.ChecklistItem{}).Where("rank = ''").Distinct().Pluck("note_id", &noteIDs).Error; err != nil {
+		return err
+	}
+	for _, noteID := range noteIDs {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var ids []uuid.UUID
+			if err := tx.Unscoped().Model(&models.ChecklistItem{}).Where("note_id = ?", noteID).Order(itemOrder).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for i, newRank := range rank.Sequence(len(ids)) {
+				if err := tx.Unscoped().Model(&models.ChecklistItem{}).Where("id = ?", ids[i]).UpdateColumn("rank", newRank).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if hasPosition {
+		if err := r.db.Migrator().DropColumn(&models.ChecklistItem{}, "position"); err != nil {
+			return err
+		}
+	}
+
+	var owners []string
+	if err := r.db.Unscoped().Model(&models.Note{}).Where("rank = ''").Distinct().Pluck("created_by", &owners).Error; err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			for _, pinned := range []bool{true, false} {
+				var ids []uuid.UUID
+				if err := tx.Unscoped().Model(&models.Note{}).
+					Where("created_by = ? AND is_pinned = ?", owner, pinned).
+					Order("updated_at DESC").
+					Pluck("id", &ids).Error; err != nil {
+					return err
+				}
+				for i, newRank := range rank.Sequence(len(ids)) {
+					if err := tx.Unscoped().Model(&models.Note{}).Where("id = ?", ids[i]).UpdateColumn("rank", newRank).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // List all notes
@@ -58,29 +670,705 @@ func (r *NoteRepository) GetAll() ([]models.Note, error) {
 	return notes, err
 }
 
-// CreateChecklistItem saves a checklist item linked to a note
+// CreateChecklistItem saves a checklist item linked to a note, placing it after the note's existing items
 func (r *NoteRepository) CreateChecklistItem(item *models.ChecklistItem) error {
-	return r.db.Create(item).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the note row so concurrent appends don't share a rank
+		if err := lockNote(tx, item.NoteID); err != nil {
+			return err
+		}
+		var last []string
+		if err := tx.Model(&models.ChecklistItem{}).
+			Where("note_id = ?", item.NoteID).
+			Order(`checklist_items.rank COLLATE "C" DESC`).
+			Limit(1).
+			Pluck("rank", &last).Error; err != nil {
+			return err
+		}
+		after := ""
+		if len(last) > 0 {
+			after = last[0]
+		}
+		var err error
+		if item.Rank, err = rank.Between(after, ""); err != nil {
+			return err
+		}
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		return rollUpChecklist(tx, item.ParentID)
+	})
+}
+
+// ReplaceChecklistItems makes a note's checklist match items, in order. Items whose ID
+// already belongs to the note are updated in place so they keep their identity and
+// CreatedAt; items without a known ID are created and the rest are soft-deleted. The ID a
+// new item was sent with is a temporary one other items can name as their ParentID. A
+// ParentID is kept only if it points at another kept item without forming a cycle. Checking
+// or unchecking an item carries over to its sub-tasks and rolls up as SetChecklistItemChecked does.
+func (r *NoteRepository) ReplaceChecklistItems(noteID uuid.UUID, items []models.ChecklistItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockNote(tx, noteID); err != nil {
+			return err
+		}
+		var existing []models.ChecklistItem
+		if err := tx.Where("note_id = ?", noteID).Find(&existing).Error; err != nil {
+			return err
+		}
+		wasChecked := make(map[uuid.UUID]bool, len(existing))
+		for _, item := range existing {
+			wasChecked[item.ID] = item.IsChecked
+		}
+
+		// Give new items their real IDs and point their sub-tasks at them
+		ids := make([]uuid.UUID, len(items))
+		tempIDs := make(map[uuid.UUID]uuid.UUID)
+		for i, item := range items {
+			ids[i] = item.ID
+			if _, known := wasChecked[item.ID]; !known {
+				ids[i] = uuid.New()
+				if item.ID != uuid.Nil {
+					tempIDs[item.ID] = ids[i]
+				}
+			}
+		}
+		parents := make(map[uuid.UUID]*uuid.UUID, len(items))
+		for i, item := range items {
+			parentID := item.ParentID
+			if parentID != nil {
+				if realID, ok := tempIDs[*parentID]; ok {
+					parentID = &realID
+				}
+			}
+			parents[ids[i]] = parentID
+		}
+		validParent := func(id uuid.UUID, parentID *uuid.UUID) *uuid.UUID {
+			if parentID == nil {
+				return nil
+			}
+			// Walk up from the parent; reaching id again (or running longer than the list) is a cycle
+			steps := 0
+			for p := parentID; p != nil; p = parents[*p] {
+				if _, kept := parents[*p]; !kept || *p == id || steps > len(parents) {
+					return nil
+				}
+				steps++
+			}
+			return parentID
+		}
+
+		ranks := rank.Sequence(len(items))
+		keep := []uuid.UUID{uuid.Nil}
+		var toggled []uuid.UUID
+		for i, item := range items {
+			id := ids[i]
+			keep = append(keep, id)
+			if checked, known := wasChecked[id]; known {
+				err := tx.Model(&models.ChecklistItem{}).Where("id = ?", id).Updates(map[string]interface{}{
+					"parent_id":  validParent(id, parents[id]),
+					"text":       item.Text,
+					"is_checked": item.IsChecked,
+					"rank":       ranks[i],
+					"updated_at": time.Now(),
+				}).Error
+				if err != nil {
+					return err
+				}
+				if item.IsChecked != checked {
+					toggled = append(toggled, id)
+				}
+				continue
+			}
+
+			newItem := models.ChecklistItem{
+				ID:        id,
+				NoteID:    noteID,
+				ParentID:  validParent(id, parents[id]),
+				Text:      item.Text,
+				IsChecked: item.IsChecked,
+				Rank:      ranks[i],
+			}
+			if err := tx.Create(&newItem).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("note_id = ? AND id NOT IN ?", noteID, keep).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		return settleChecklist(tx, noteID, toggled)
+	})
+}
+
+// ReorderChecklistItems sets the order of a note's checklist. itemIDs must list every
+// live item of the note exactly once, otherwise ErrInvalidOrder is returned.
+func (r *NoteRepository) ReorderChecklistItems(noteID uuid.UUID, itemIDs []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the note row so items created or moved meanwhile can't slip between the check and the new ranks
+		if err := lockNote(tx, noteID); err != nil {
+			return err
+		}
+		var current []uuid.UUID
+		if err := tx.Model(&models.ChecklistItem{}).Where("note_id = ?", noteID).Pluck("id", &current).Error; err != nil {
+			return err
+		}
+		if len(current) != len(itemIDs) {
+			return ErrInvalidOrder
+		}
+		remaining := make(map[uuid.UUID]bool, len(current))
+		for _, id := range current {
+			remaining[id] = true
+		}
+		for _, id := range itemIDs {
+			if !remaining[id] {
+				return ErrInvalidOrder
+			}
+			delete(remaining, id)
+		}
+
+		ranks := rank.Sequence(len(itemIDs))
+		for i, id := range itemIDs {
+			err := tx.Model(&models.ChecklistItem{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"rank":       ranks[i],
+				"updated_at": time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MoveChecklistItem re-ranks a checklist item between two neighbours of the same note
+func (r *NoteRepository) MoveChecklistItem(item *models.ChecklistItem, afterID, beforeID *uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the note row so concurrent moves don't pick the same rank
+		if err := lockNote(tx, item.NoteID); err != nil {
+			return err
+		}
+		siblings := tx.Model(&models.ChecklistItem{}).Where("note_id = ? AND id <> ?", item.NoteID, item.ID)
+		newRank, err := rankBetween(siblings, "checklist_items.id", `checklist_items.rank COLLATE "C"`, afterID, beforeID)
+		if err != nil {
+			return err
+		}
+		item.Rank = newRank
+		return tx.Model(item).Updates(map[string]interface{}{
+			"rank":       newRank,
+			"updated_at": time.Now(),
+		}).Error
+	})
+}
+
+// rankBetween picks a rank between two rows of list. When only one neighbour is given the
+// other is taken to be the row next to it, so the moved row ends up directly beside it; with
+// neither the row goes to the end of the list.
+func rankBetween(list *gorm.DB, idColumn, rankColumn string, afterID, beforeID *uuid.UUID) (string, error) {
+	rankOf := func(id uuid.UUID) (string, error) {
+		var ranks []string
+		err := list.Session(&gorm.Session{}).Where(idColumn+" = ?", id).Limit(1).Pluck("rank", &ranks).Error
+		if err != nil {
+			return "", err
+		}
+		if len(ranks) == 0 {
+			return "", ErrInvalidMove
+		}
+		return ranks[0], nil
+	}
+	// neighbour returns the closest rank beyond from in the given direction, or "" at the end of the list
+	neighbour := func(from string, after bool) (string, error) {
+		query := list.Session(&gorm.Session{})
+		if after {
+			query = query.Where(rankColumn+" > ?", from).Order(rankColumn)
+		} else {
+			query = query.Where(rankColumn+" < ?", from).Order(rankColumn + " DESC")
+		}
+		var ranks []string
+		if err := query.Limit(1).Pluck("rank", &ranks).Error; err != nil || len(ranks) == 0 {
+			return "", err
+		}
+		return ranks[0], nil
+	}
+
+	var lo, hi string
+	var err error
+	switch {
+	case afterID != nil && beforeID != nil:
+		if lo, err = rankOf(*afterID); err != nil {
+			return "", err
+		}
+		if hi, err = rankOf(*beforeID); err != nil {
+			return "", err
+		}
+		next, err := neighbour(lo, true)
+		if err != nil {
+			return "", err
+		}
+		if next != hi {
+			return "", ErrInvalidMove
+		}
+	case afterID != nil:
+		if lo, err = rankOf(*afterID); err != nil {
+			return "", err
+		}
+		if hi, err = neighbour(lo, true); err != nil {
+			return "", err
+		}
+	case beforeID != nil:
+		if hi, err = rankOf(*beforeID); err != nil {
+			return "", err
+		}
+		if lo, err = neighbour(hi, false); err != nil {
+			return "", err
+		}
+	default:
+		var ranks []string
+		if err := list.Session(&gorm.Session{}).Order(rankColumn+" DESC").Limit(1).Pluck("rank", &ranks).Error; err != nil {
+			return "", err
+		}
+		if len(ranks) > 0 {
+			lo = ranks[0]
+		}
+	}
+
+	newRank, err := rank.Between(lo, hi)
+	if err == rank.ErrInvalidRange {
+		return "", ErrInvalidMove
+	}
+	return newRank, err
+}
+
+// ToggleChecklistItem flips IsChecked with the same cascading rules as SetChecklistItemChecked
+func (r *NoteRepository) ToggleChecklistItem(item *models.ChecklistItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockNote(tx, item.NoteID); err != nil {
+			return err
+		}
+		// Re-read under the lock so concurrent toggles don't lose updates
+		if err := tx.First(item, "id = ?", item.ID).Error; err != nil {
+			return err
+		}
+		return setChecklistItemChecked(tx, item, !item.IsChecked)
+	})
+}
+
+// SetChecklistItemChecked checks or unchecks an item together with all of its sub-tasks,
+// then rolls the change up: a parent is checked exactly when all of its sub-tasks are
+func (r *NoteRepository) SetChecklistItemChecked(item *models.ChecklistItem, checked bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockNote(tx, item.NoteID); err != nil {
+			return err
+		}
+		return setChecklistItemChecked(tx, item, checked)
+	})
+}
+
+func setChecklistItemChecked(tx *gorm.DB, item *models.ChecklistItem, checked bool) error {
+	ids, err := checklistDescendantIDs(tx, item.ID)
+	if err != nil {
+		return err
+	}
+	ids = append(ids, item.ID)
+	err = tx.Model(&models.ChecklistItem{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"is_checked": checked,
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := rollUpChecklist(tx, item.ParentID); err != nil {
+		return err
+	}
+	return tx.First(item, "id = ?", item.ID).Error
+}
+
+// SettleChecklist brings a note's checklist in line after several of its items were written
+// at once: each toggled item passes its checked state down to its sub-tasks, then every parent
+// is checked exactly when all of its sub-tasks are, as SetChecklistItemChecked does for one item
+func (r *NoteRepository) SettleChecklist(noteID uuid.UUID, toggled []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockNote(tx, noteID); err != nil {
+			return err
+		}
+		return settleChecklist(tx, noteID, toggled)
+	})
+}
+
+func settleChecklist(tx *gorm.DB, noteID uuid.UUID, toggled []uuid.UUID) error {
+	var items []models.ChecklistItem
+	if err := tx.Where("note_id = ?", noteID).Find(&items).Error; err != nil {
+		return err
+	}
+	checked := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		checked[item.ID] = item.IsChecked
+	}
+	children := make(map[uuid.UUID][]uuid.UUID)
+	var roots []uuid.UUID
+	for _, item := range items {
+		if item.ParentID != nil {
+			if _, ok := checked[*item.ParentID]; ok {
+				children[*item.ParentID] = append(children[*item.ParentID], item.ID)
+				continue
+			}
+		}
+		roots = append(roots, item.ID)
+	}
+	isToggled := make(map[uuid.UUID]bool, len(toggled))
+	for _, id := range toggled {
+		isToggled[id] = true
+	}
+
+	// Toggles win over those of their sub-tasks; parents are settled after their sub-tasks
+	var settle func(id uuid.UUID, forced *bool)
+	settle = func(id uuid.UUID, forced *bool) {
+		if forced != nil {
+			checked[id] = *forced
+		} else if isToggled[id] {
+			state := checked[id]
+			forced = &state
+		}
+		for _, child := range children[id] {
+			settle(child, forced)
+		}
+		if len(children[id]) > 0 {
+			all := true
+			for _, child := range children[id] {
+				all = all && checked[child]
+			}
+			checked[id] = all
+		}
+	}
+	for _, id := range roots {
+		settle(id, nil)
+	}
+
+	changed := map[bool][]uuid.UUID{}
+	for _, item := range items {
+		if checked[item.ID] != item.IsChecked {
+			changed[checked[item.ID]] = append(changed[checked[item.ID]], item.ID)
+		}
+	}
+	for state, ids := range changed {
+		err := tx.Model(&models.ChecklistItem{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"is_checked": state,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollUpChecklist re-derives the checked state of parentID and its ancestors from their sub-tasks,
+// for use after an item is added to, removed from or moved between sub-task lists
+func (r *NoteRepository) RollUpChecklist(parentID *uuid.UUID) error {
+	return rollUpChecklist(r.db, parentID)
+}
+
+func rollUpChecklist(tx *gorm.DB, parentID *uuid.UUID) error {
+	for parentID != nil {
+		var parent models.ChecklistItem
+		if err := tx.First(&parent, "id = ?", *parentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		var total, unchecked int64
+		children := tx.Model(&models.ChecklistItem{}).Where("parent_id = ?", parent.ID)
+		if err := children.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return err
+		}
+		if err := children.Session(&gorm.Session{}).Where("is_checked = ?", false).Count(&unchecked).Error; err != nil {
+			return err
+		}
+
+		// A parent that lost its last sub-task keeps whatever state it had; an unchanged
+		// parent means nothing further up can change either
+		checked := unchecked == 0
+		if total == 0 || parent.IsChecked == checked {
+			return nil
+		}
+		err := tx.Model(&parent).Updates(map[string]interface{}{
+			"is_checked": checked,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// ValidateChecklistParent checks that parentID can become the parent of itemID: it must be a
+// live item of the same note and must not be the item itself or one of its sub-tasks
+func (r *NoteRepository) ValidateChecklistParent(noteID, itemID, parentID uuid.UUID) error {
+	if parentID == itemID {
+		return ErrInvalidParent
+	}
+	var count int64
+	if err := r.db.Model(&models.ChecklistItem{}).Where("id = ? AND note_id = ?", parentID, noteID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidParent
+	}
+	descendants, err := checklistDescendantIDs(r.db, itemID)
+	if err != nil {
+		return err
+	}
+	for _, id := range descendants {
+		if id == parentID {
+			return ErrInvalidParent
+		}
+	}
+	return nil
+}
+
+// checklistDescendantIDs returns the IDs of all live sub-tasks below an item, at any depth
+func checklistDescendantIDs(tx *gorm.DB, itemID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := tx.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id FROM checklist_items WHERE parent_id = ? AND deleted_at IS NULL
+			UNION
+			SELECT c.id FROM checklist_items c JOIN subtree s ON c.parent_id = s.id WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM subtree`, itemID).Scan(&ids).Error
+	return ids, err
+}
+
+// lockNote takes a row lock on a note so changes to its checklist are serialized
+func lockNote(tx *gorm.DB, noteID uuid.UUID) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Note{}, "id = ?", noteID).Error
 }
 
-// CreateReminder saves a reminder linked to a note
+// Touch marks a note as edited by userID after one of its children changed, bumping its version
+func (r *NoteRepository) Touch(note *models.Note, userID string) error {
+	return r.db.Model(note).
+		Clauses(clause.Returning{}).
+		Updates(map[string]interface{}{
+			"version":    gorm.Expr("version + 1"),
+			"updated_by": userID,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// CreateReminder saves a reminder linked to a note, due at its first occurrence
 func (r *NoteRepository) CreateReminder(reminder *models.Reminder) error {
+	if err := scheduleReminder(reminder); err != nil {
+		return err
+	}
 	return r.db.Create(reminder).Error
 }
 
-// DeleteChecklistItemsByNote deletes all checklist items for a note (used in update)
+// DeleteChecklistItemsByNote soft-deletes all checklist items for a note (used in update)
 func (r *NoteRepository) DeleteChecklistItemsByNote(noteID uuid.UUID) error {
 	return r.db.Where("note_id = ?", noteID).Delete(&models.ChecklistItem{}).Error
 }
 
-// DeleteRemindersByNote deletes all reminders for a note (used in update)
+// DeleteRemindersByNote soft-deletes all reminders for a note (used in update)
 func (r *NoteRepository) DeleteRemindersByNote(noteID uuid.UUID) error {
 	return r.db.Where("note_id = ?", noteID).Delete(&models.Reminder{}).Error
 }
 
+// Get checklist item by ID (UUID)
+func (r *NoteRepository) GetChecklistItemByID(id uuid.UUID) (*models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := r.db.First(&item, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetTrashedChecklistItemByID loads a soft-deleted checklist item
+func (r *NoteRepository) GetTrashedChecklistItemByID(id uuid.UUID) (*models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&item, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetChecklistItem loads a checklist item only if it belongs to the given note
+func (r *NoteRepository) GetChecklistItem(noteID, itemID uuid.UUID) (*models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := r.db.Where("note_id = ?", noteID).First(&item, "id = ?", itemID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Update a checklist item
+func (r *NoteRepository) UpdateChecklistItem(item *models.ChecklistItem) error {
+	return r.db.Save(item).Error
+}
+
+// DeleteChecklistItem soft-deletes a checklist item along with its sub-tasks
+func (r *NoteRepository) DeleteChecklistItem(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var item models.ChecklistItem
+		if err := tx.First(&item, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		ids, err := checklistDescendantIDs(tx, item.ID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, item.ID)
+		if err := tx.Where("id IN ?", ids).Delete(&models.ChecklistItem{}).Error; err != nil {
+			return err
+		}
+		// The parent may now have only checked sub-tasks left
+		return rollUpChecklist(tx, item.ParentID)
+	})
+}
+
+// Get reminder by ID (UUID)
+func (r *NoteRepository) GetReminderByID(id uuid.UUID) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.db.First(&reminder, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// GetTrashedReminderByID loads a soft-deleted reminder
+func (r *NoteRepository) GetTrashedReminderByID(id uuid.UUID) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&reminder, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// GetReminder loads a reminder only if it belongs to the given note
+func (r *NoteRepository) GetReminder(noteID, reminderID uuid.UUID) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.db.Where("note_id = ?", noteID).First(&reminder, "id = ?", reminderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// Update a reminder. Changing when it fires makes it due for delivery again from its first
+// occurrence, and drops overrides made for the old schedule.
+func (r *NoteRepository) UpdateReminder(reminder *models.Reminder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stored models.Reminder
+		err := tx.Select("time", "rrule", "timezone").First(&stored, "id = ?", reminder.ID).Error
+		if err == nil && !sameSchedule(&stored, reminder) {
+			if err := scheduleReminder(reminder); err != nil {
+				return err
+			}
+			if err := tx.Where("reminder_id = ?", reminder.ID).Delete(&models.ReminderOverride{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(reminder).Error
+	})
+}
+
+// ReplaceReminders makes a note's reminders match the given ones. Reminders that already fire on
+// the same schedule are kept with their delivery state, overrides and snoozes, so editing a note
+// doesn't re-send reminders that fired; only their time zone is brought up to date.
+func (r *NoteRepository) ReplaceReminders(noteID uuid.UUID, reminders []models.Reminder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Reminder
+		if err := tx.Where("note_id = ?", noteID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		keep := []uuid.UUID{uuid.Nil}
+		used := make(map[uuid.UUID]bool)
+	nextReminder:
+		for _, want := range reminders {
+			for _, reminder := range existing {
+				if !used[reminder.ID] && sameSchedule(&reminder, &want) {
+					used[reminder.ID] = true
+					keep = append(keep, reminder.ID)
+					if reminder.Timezone != want.Timezone {
+						err := tx.Model(&models.Reminder{}).Where("id = ?", reminder.ID).Update("timezone", want.Timezone).Error
+						if err != nil {
+							return err
+						}
+					}
+					continue nextReminder
+				}
+			}
+			reminder := models.Reminder{ID: uuid.New(), NoteID: noteID, Time: want.Time, RRule: want.RRule, Timezone: want.Timezone}
+			if err := scheduleReminder(&reminder); err != nil {
+				return err
+			}
+			if err := tx.Create(&reminder).Error; err != nil {
+				return err
+			}
+			keep = append(keep, reminder.ID)
+		}
+
+		return tx.Where("note_id = ? AND id NOT IN ?", noteID, keep).Delete(&models.Reminder{}).Error
+	})
+}
+
+// sameSchedule reports whether two reminders fire at the same times. The time zone only matters
+// for repeating reminders, and an empty one is UTC as in ReminderLocation.
+func sameSchedule(a, b *models.Reminder) bool {
+	if !a.Time.Equal(b.Time) || a.RRule != b.RRule {
+		return false
+	}
+	return a.RRule == "" || reminderZone(a.Timezone) == reminderZone(b.Timezone)
+}
+
+func reminderZone(timezone string) string {
+	if timezone == "" {
+		return time.UTC.String()
+	}
+	return timezone
+}
+
+// scheduleReminder resets a reminder's delivery state and makes it due at its first occurrence
+func scheduleReminder(reminder *models.Reminder) error {
+	first, err := ReminderOccurrences(reminder, nil, time.Time{}, 1)
+	if err != nil {
+		return err
+	}
+	resetReminderDelivery(reminder)
+	reminder.Status = models.ReminderStatusPending
+	reminder.DueAt = nil
+	if len(first) > 0 {
+		reminder.DueAt = &first[0]
+	}
+	return nil
+}
+
+func resetReminderDelivery(reminder *models.Reminder) {
+	reminder.DeliveredAt = nil
+	reminder.DeliveredVia = ""
+	reminder.Attempts = 0
+	reminder.NextAttemptAt = nil
+	reminder.LastError = ""
+}
+
+// DeleteReminder soft-deletes a single reminder
+func (r *NoteRepository) DeleteReminder(id uuid.UUID) error {
+	return r.db.Delete(&models.Reminder{}, "id = ?", id).Error
+}
+
 func (r *NoteRepository) GetChecklistItemsByNoteID(noteID uuid.UUID) ([]models.ChecklistItem, error) {
 	var items []models.ChecklistItem
-	err := r.db.Where("note_id = ?", noteID).Find(&items).Error
+	err := r.db.Where("note_id = ?", noteID).Order(checklistOrder).Find(&items).Error
 	return items, err
 }
 
@@ -89,3 +1377,36 @@ func (r *NoteRepository) GetRemindersByNoteID(noteID uuid.UUID) ([]models.Remind
 	err := r.db.Where("note_id = ?", noteID).Find(&reminders).Error
 	return reminders, err
 }
+
+// GetTrashedChecklistItemsByNoteID returns the items trashed together with a note
+func (r *NoteRepository) GetTrashedChecklistItemsByNoteID(noteID uuid.UUID, deletedAt time.Time) ([]models.ChecklistItem, error) {
+	var items []models.ChecklistItem
+	err := r.db.Unscoped().Where("note_id = ? AND deleted_at = ?", noteID, deletedAt).Order(checklistOrder).Find(&items).Error
+	return items, err
+}
+
+// GetTrashedRemindersByNoteID returns the reminders trashed together with a note
+func (r *NoteRepository) GetTrashedRemindersByNoteID(noteID uuid.UUID, deletedAt time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Unscoped().Where("note_id = ? AND deleted_at = ?", noteID, deletedAt).Find(&reminders).Error
+	return reminders, err
+}
+
+// ReplaceLabels sets the labels attached to a note, dropping any previous ones
+func (r *NoteRepository) ReplaceLabels(noteID uuid.UUID, labels []models.Label) error {
+	note := models.Note{ID: noteID}
+	if len(labels) == 0 {
+		return r.db.Model(&note).Association("Labels").Clear()
+	}
+	return r.db.Model(&note).Association("Labels").Replace(labels)
+}
+
+func (r *NoteRepository) GetLabelsByNoteID(noteID uuid.UUID) ([]models.Label, error) {
+	var labels []models.Label
+	err := r.db.
+		Joins("JOIN note_labels ON note_labels.label_id = labels.id").
+		Where("note_labels.note_id = ?", noteID).
+		Order("labels.name").
+		Find(&labels).Error
+	return labels, err
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"time"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Create a new notification
+func (r *NotificationRepository) Create(notification *models.Notification) error {
+	return r.db.Create(notification).Error
+}
+
+// CreateOnce creates a notification unless the user already has one for the same reminder
+// occurrence, reporting whether it did
+func (r *NotificationRepository) CreateOnce(notification *models.Notification) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetAllByUser lists a user's notifications, newest first
+func (r *NotificationRepository) GetAllByUser(userID string, unreadOnly bool, limit int) ([]models.Notification, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []models.Notification
+	err := query.Order("created_at DESC").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkRead marks one of a user's notifications as read, returning gorm.ErrRecordNotFound if it isn't theirs
+func (r *NotificationRepository) MarkRead(id uuid.UUID, userID string) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.db.Where("user_id = ?", userID).First(&notification, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := r.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &notification, nil
+}
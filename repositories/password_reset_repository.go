@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a new reset token, voiding any earlier unused ones so only the latest email works
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ResetPassword uses up the reset token with tokenHash, sets its user's password hash and ends
+// all of the user's sessions, returning the user's ID. Having opened the emailed link, the user
+// has also proved they own their email address.
+func (r *PasswordResetRepository) ResetPassword(tokenHash, passwordHash string) (uuid.UUID, error) {
+	var token models.PasswordResetToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&token).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+			return ErrResetTokenInvalid
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.User{}).Where("id = ?", token.UserID).Updates(map[string]interface{}{
+			"password":       passwordHash,
+			"email_verified": true,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		return NewRefreshTokenRepository(tx).RevokeAllForUser(token.UserID)
+	})
+	return token.UserID, err
+}
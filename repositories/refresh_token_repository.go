@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused means an already used refresh token was presented; its session has been revoked
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create stores the first refresh token of a new session
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	if token.FamilyID == uuid.Nil {
+		token.FamilyID = uuid.New()
+	}
+	return r.db.Create(token).Error
+}
+
+// Rotate uses up the refresh token with tokenHash and stores next, which joins its session, in
+// its place. Presenting a token that was already used revokes the whole session and returns
+// ErrRefreshTokenReused.
+func (r *RefreshTokenRepository) Rotate(tokenHash string, next *models.RefreshToken) error {
+	reused := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the row so two concurrent refreshes with the same token can't both succeed
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).First(&current).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case current.RevokedAt != nil || !current.ExpiresAt.After(now):
+			return ErrRefreshTokenInvalid
+		case current.UsedAt != nil:
+			reused = true
+			return revokeFamily(tx, current.FamilyID, now)
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		return tx.Create(next).Error
+	})
+	if err == nil && reused {
+		return ErrRefreshTokenReused
+	}
+	return err
+}
+
+// RevokeSession revokes every refresh token in the session the token with tokenHash belongs to.
+// Unknown tokens are ignored.
+func (r *RefreshTokenRepository) RevokeSession(tokenHash string) error {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return revokeFamily(r.db, token.FamilyID, time.Now())
+}
+
+// RevokeAllForUser ends every session of a user
+func (r *RefreshTokenRepository) RevokeAllForUser(userID uuid.UUID) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func revokeFamily(db *gorm.DB, familyID uuid.UUID, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
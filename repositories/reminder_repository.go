@@ -0,0 +1,363 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+	"todo-backend/models"
+	"todo-backend/rrule"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotRecurring = errors.New("reminder does not repeat")
+
+var ErrNotAnOccurrence = errors.New("time is not an occurrence of the reminder")
+
+// reminderDeliveryColumns are the columns that track where a reminder stands in its lifecycle
+var reminderDeliveryColumns = []string{"status", "due_at", "delivered_at", "delivered_via", "attempts", "next_attempt_at", "last_error"}
+
+type ReminderRepository struct {
+	db *gorm.DB
+}
+
+func NewReminderRepository(db *gorm.DB) *ReminderRepository {
+	return &ReminderRepository{db: db}
+}
+
+// DueReminderQuery selects the reminders a scheduler run should deliver
+type DueReminderQuery struct {
+	Now         time.Time
+	MaxAttempts int
+	Limit       int
+}
+
+// ClaimDue leases a batch of due reminders to the caller until `until`, skipping any another
+// scheduler instance is claiming at the same moment. The lease is kept in next_attempt_at, so
+// no other instance picks the reminders up while they are being sent, and a crashed instance's
+// reminders become due again once its lease runs out. No locks are held after it returns.
+func (r *ReminderRepository) ClaimDue(q DueReminderQuery, until time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND attempts < ?", q.MaxAttempts).
+			Where("COALESCE(next_attempt_at, due_at) <= ?", q.Now).
+			Where("note_id IN (SELECT id FROM notes WHERE deleted_at IS NULL)").
+			Order("due_at").
+			Limit(q.Limit).
+			Find(&reminders).Error
+		if err != nil || len(reminders) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(reminders))
+		for i := range reminders {
+			ids[i] = reminders[i].ID
+			reminders[i].NextAttemptAt = &until
+		}
+		return tx.Model(&models.Reminder{}).Where("id IN ?", ids).UpdateColumn("next_attempt_at", until).Error
+	})
+	return reminders, err
+}
+
+// RecordDelivery saves the delivery state left on a reminder that was claimed until `lease` for
+// its occurrence at dueAt. A reminder changed meanwhile (snoozed, dismissed, rescheduled or
+// deleted) no longer holds the lease and keeps its new state. Once the occurrence is finished,
+// its per-recipient delivery records are dropped.
+func (r *ReminderRepository) RecordDelivery(reminder *models.Reminder, dueAt, lease time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(reminder).
+			Where("next_attempt_at = ?", lease).
+			Select(reminderDeliveryColumns).
+			Updates(reminder)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if reminder.DeliveredAt == nil && reminder.DueAt != nil && reminder.DueAt.Equal(dueAt) {
+			return nil
+		}
+		return tx.Where("reminder_id = ? AND due_at = ?", reminder.ID, dueAt).Delete(&models.ReminderDelivery{}).Error
+	})
+}
+
+// GetDeliveries lists who an occurrence of a reminder has already reached, and on which channels
+func (r *ReminderRepository) GetDeliveries(reminderID uuid.UUID, dueAt time.Time) ([]models.ReminderDelivery, error) {
+	var deliveries []models.ReminderDelivery
+	err := r.db.Where("reminder_id = ? AND due_at = ?", reminderID, dueAt).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// MarkDelivered records that an occurrence reached a recipient on a channel
+func (r *ReminderRepository) MarkDelivered(delivery *models.ReminderDelivery) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery).Error
+}
+
+// EnsureDueTimes schedules reminders stored before due_at existed for their one-off time
+func (r *ReminderRepository) EnsureDueTimes() error {
+	return r.db.Unscoped().Model(&models.Reminder{}).Where("due_at IS NULL").UpdateColumn("due_at", gorm.Expr("time")).Error
+}
+
+// EnsureStatuses marks reminders delivered before statuses existed as fired
+func (r *ReminderRepository) EnsureStatuses() error {
+	return r.db.Unscoped().Model(&models.Reminder{}).
+		Where("status = ? AND delivered_at IS NOT NULL", models.ReminderStatusPending).
+		UpdateColumn("status", models.ReminderStatusFired).Error
+}
+
+// GetActiveByUser returns the reminders on live notes a user owns or collaborates on that may still
+// fire, with their note loaded
+func (r *ReminderRepository) GetActiveByUser(userID string) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Preload("Note").
+		Where("note_id IN (?)", r.db.Model(&models.Note{}).Select("notes.id").Where(accessibleByUser, sql.Named("user", userID))).
+		Where("delivered_at IS NULL OR rrule <> ''").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// Snooze puts a reminder off until the given time, when it fires again. A recurring reminder
+// carries on with its schedule after that, skipping any occurrences the snooze ran past.
+func (r *ReminderRepository) Snooze(reminder *models.Reminder, until time.Time) error {
+	resetReminderDelivery(reminder)
+	reminder.Status = models.ReminderStatusSnoozed
+	reminder.DueAt = &until
+	return r.db.Model(reminder).Select(reminderDeliveryColumns).Updates(reminder).Error
+}
+
+// Dismiss acknowledges a reminder's current occurrence. One that hasn't fired yet (or is snoozed)
+// is cancelled: a one-off reminder won't fire at all, a recurring one moves on to its next occurrence.
+func (r *ReminderRepository) Dismiss(reminder *models.Reminder) error {
+	waiting := reminder.Status == models.ReminderStatusPending || reminder.Status == models.ReminderStatusSnoozed
+	if waiting && reminder.DeliveredAt == nil && reminder.DueAt != nil {
+		next, err := r.NextOccurrence(reminder, *reminder.DueAt)
+		if err != nil {
+			return err
+		}
+		resetReminderDelivery(reminder)
+		if next != nil && reminder.RRule != "" {
+			reminder.DueAt = next
+		} else {
+			now := time.Now()
+			reminder.DeliveredAt = &now
+		}
+	}
+	reminder.Status = models.ReminderStatusDismissed
+	return r.db.Model(reminder).Select(reminderDeliveryColumns).Updates(reminder).Error
+}
+
+func (r *ReminderRepository) GetOverrides(reminderID uuid.UUID) ([]models.ReminderOverride, error) {
+	var overrides []models.ReminderOverride
+	err := r.db.Where("reminder_id = ?", reminderID).Find(&overrides).Error
+	return overrides, err
+}
+
+// NextOccurrence returns the first time a reminder fires strictly after `after`, or nil when it never fires again
+func (r *ReminderRepository) NextOccurrence(reminder *models.Reminder, after time.Time) (*time.Time, error) {
+	overrides, err := r.GetOverrides(reminder.ID)
+	if err != nil {
+		return nil, err
+	}
+	return nextOccurrence(reminder, overrides, after)
+}
+
+// Upcoming returns up to n times a reminder will fire from now on, up to `until` unless that is zero
+func (r *ReminderRepository) Upcoming(reminder *models.Reminder, until time.Time, n int) ([]time.Time, error) {
+	var overrides []models.ReminderOverride
+	if reminder.RRule != "" {
+		var err error
+		if overrides, err = r.GetOverrides(reminder.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The pending (possibly snoozed) delivery comes first, then the rest of the schedule
+	from := time.Now()
+	var out []time.Time
+	if reminder.DeliveredAt == nil && reminder.DueAt != nil && !reminder.DueAt.Before(from) {
+		out = append(out, *reminder.DueAt)
+		from = reminder.DueAt.Truncate(time.Second).Add(time.Second)
+	}
+	if reminder.RRule != "" && len(out) < n {
+		more, err := ReminderOccurrences(reminder, overrides, from, n-len(out))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, more...)
+	}
+
+	if !until.IsZero() {
+		for i, t := range out {
+			if t.After(until) {
+				return out[:i], nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// OverrideOccurrence moves (or, with a nil moved time, skips) the occurrence of a recurring reminder
+// due at occurrence and reschedules the reminder's delivery. occurrence may be the original time
+// of the occurrence or the time it was already moved to.
+func (r *ReminderRepository) OverrideOccurrence(reminder *models.Reminder, occurrence time.Time, moved *time.Time) error {
+	if reminder.RRule == "" {
+		return ErrNotRecurring
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var overrides []models.ReminderOverride
+		if err := tx.Where("reminder_id = ?", reminder.ID).Find(&overrides).Error; err != nil {
+			return err
+		}
+
+		original := occurrence
+		for _, o := range overrides {
+			if o.Time != nil && o.Time.Equal(occurrence) {
+				original = o.OriginalTime
+			}
+		}
+		ok, err := isOccurrence(reminder, original)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotAnOccurrence
+		}
+
+		override := models.ReminderOverride{ReminderID: reminder.ID, OriginalTime: original, Time: moved}
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "reminder_id"}, {Name: "original_time"}},
+			DoUpdates: clause.AssignmentColumns([]string{"time", "updated_at"}),
+		}).Create(&override).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("reminder_id = ?", reminder.ID).Find(&overrides).Error; err != nil {
+			return err
+		}
+
+		// A snoozed occurrence stays put; the schedule resumes after it
+		if reminder.Status == models.ReminderStatusSnoozed && reminder.DeliveredAt == nil {
+			return nil
+		}
+
+		// Pick up where delivery stands: an occurrence that is due but not yet sent stays
+		// in play, otherwise only future ones count
+		from := time.Now()
+		if reminder.DeliveredAt == nil && reminder.DueAt != nil && reminder.DueAt.Before(from) {
+			from = *reminder.DueAt
+		}
+		next, err := ReminderOccurrences(reminder, overrides, from, 1)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(next) == 0:
+			if reminder.DeliveredAt == nil {
+				now := time.Now()
+				reminder.DeliveredAt = &now
+			}
+		case reminder.DeliveredAt != nil || reminder.DueAt == nil || !reminder.DueAt.Equal(next[0]):
+			resetReminderDelivery(reminder)
+			reminder.DueAt = &next[0]
+		}
+		return tx.Model(reminder).Select(reminderDeliveryColumns).Updates(reminder).Error
+	})
+}
+
+// ReminderLocation returns the time zone a reminder's recurrence is evaluated in
+func ReminderLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// ValidateReminder checks a reminder's recurrence rule and time zone
+func ValidateReminder(reminder *models.Reminder) error {
+	if _, err := ReminderLocation(reminder.Timezone); err != nil {
+		return errors.New("unknown timezone " + reminder.Timezone)
+	}
+	if reminder.RRule == "" {
+		return nil
+	}
+	if _, err := rrule.Parse(reminder.RRule); err != nil {
+		return err
+	}
+	first, err := ReminderOccurrences(reminder, nil, time.Time{}, 1)
+	if err != nil {
+		return err
+	}
+	if len(first) == 0 {
+		return errors.New("recurrence rule has no occurrences")
+	}
+	return nil
+}
+
+// ReminderOccurrences returns up to n times, in order, at or after from that a reminder fires. A
+// recurring reminder's rule is expanded from Time in the reminder's time zone, so occurrences keep
+// their wall-clock time across DST changes; overrides then move or skip single occurrences.
+func ReminderOccurrences(reminder *models.Reminder, overrides []models.ReminderOverride, from time.Time, n int) ([]time.Time, error) {
+	if reminder.RRule == "" {
+		if n < 1 || reminder.Time.Before(from) {
+			return nil, nil
+		}
+		return []time.Time{reminder.Time}, nil
+	}
+	rule, err := rrule.Parse(reminder.RRule)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := ReminderLocation(reminder.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := make(map[int64]bool, len(overrides))
+	var out []time.Time
+	for _, o := range overrides {
+		overridden[o.OriginalTime.Unix()] = true
+		if o.Time != nil && !o.Time.Before(from) {
+			out = append(out, *o.Time)
+		}
+	}
+
+	// Any of the first n results that isn't a moved occurrence is among the first n generated ones
+	generated := 0
+	it := rule.Iter(reminder.Time.Truncate(time.Second).In(loc))
+	for generated < n {
+		t, ok := it.Next()
+		if !ok {
+			break
+		}
+		if t.Before(from) || overridden[t.Unix()] {
+			continue
+		}
+		out = append(out, t)
+		generated++
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out, nil
+}
+
+func nextOccurrence(reminder *models.Reminder, overrides []models.ReminderOverride, after time.Time) (*time.Time, error) {
+	next, err := ReminderOccurrences(reminder, overrides, after.Truncate(time.Second).Add(time.Second), 1)
+	if err != nil || len(next) == 0 {
+		return nil, err
+	}
+	return &next[0], nil
+}
+
+// isOccurrence reports whether the reminder's rule (ignoring overrides) produces t
+func isOccurrence(reminder *models.Reminder, t time.Time) (bool, error) {
+	next, err := ReminderOccurrences(reminder, nil, t, 1)
+	if err != nil || len(next) == 0 {
+		return false, err
+	}
+	return next[0].Unix() == t.Unix(), nil
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevisionRepository struct {
+	db *gorm.DB
+}
+
+func NewRevisionRepository(db *gorm.DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+// Record snapshots the current stored state of a note, including one in the trash, as its next revision
+func (r *RevisionRepository) Record(noteID uuid.UUID, editedBy string) (*models.NoteRevision, error) {
+	var revision models.NoteRevision
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the note row so concurrent updates get consecutive revision numbers
+		var note models.Note
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&note, "id = ?", noteID).Error; err != nil {
+			return err
+		}
+
+		var items []models.ChecklistItem
+		if err := tx.Where("note_id = ?", noteID).Order(checklistOrder).Find(&items).Error; err != nil {
+			return err
+		}
+		checklist := models.ChecklistSnapshot{}
+		for _, item := range items {
+			snap := models.ChecklistSnapshotItem{
+				ID:        item.ID,
+				Text:      item.Text,
+				IsChecked: item.IsChecked,
+			}
+			if item.ParentID != nil {
+				snap.ParentID = *item.ParentID
+			}
+			checklist = append(checklist, snap)
+		}
+
+		var last int
+		if err := tx.Model(&models.NoteRevision{}).
+			Where("note_id = ?", noteID).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		revision = models.NoteRevision{
+			ID:          uuid.New(),
+			NoteID:      noteID,
+			Revision:    last + 1,
+			Title:       note.Title,
+			Description: note.Description,
+			IsPinned:    note.IsPinned,
+			IsArchived:  note.IsArchived,
+			IsChecklist: note.IsChecklist,
+			Checklist:   checklist,
+			EditedBy:    editedBy,
+		}
+		return tx.Create(&revision).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// EnsureBaselines records the current state of notes stored before revisions existed as their
+// first revision, so the content they had before their next edit isn't lost
+func (r *RevisionRepository) EnsureBaselines() error {
+	var notes []models.Note
+	err := r.db.Unscoped().
+		Select("id", "created_by", "updated_by").
+		Where("NOT EXISTS (SELECT 1 FROM note_revisions WHERE note_revisions.note_id = notes.id)").
+		Find(&notes).Error
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		editedBy := note.UpdatedBy
+		if editedBy == "" {
+			editedBy = note.CreatedBy
+		}
+		if _, err := r.Record(note.ID, editedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get all revisions of a note, newest first
+func (r *RevisionRepository) GetAllByNote(noteID uuid.UUID) ([]models.NoteRevision, error) {
+	var revisions []models.NoteRevision
+	err := r.db.Where("note_id = ?", noteID).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// Get a single revision of a note by its number
+func (r *RevisionRepository) GetByNumber(noteID uuid.UUID, revision int) (*models.NoteRevision, error) {
+	var rev models.NoteRevision
+	err := r.db.Where("note_id = ? AND revision = ?", noteID, revision).First(&rev).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"time"
+	"todo-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ShareLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepository(db *gorm.DB) *ShareLinkRepository {
+	return &ShareLinkRepository{db: db}
+}
+
+// Create a new share link
+func (r *ShareLinkRepository) Create(link *models.NoteShareLink) error {
+	return r.db.Create(link).Error
+}
+
+// Get all share links issued for a note, newest first
+func (r *ShareLinkRepository) GetAllByNote(noteID uuid.UUID) ([]models.NoteShareLink, error) {
+	var links []models.NoteShareLink
+	err := r.db.Where("note_id = ?", noteID).Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+// Get a share link by ID (UUID)
+func (r *ShareLinkRepository) GetByID(id uuid.UUID) (*models.NoteShareLink, error) {
+	var link models.NoteShareLink
+	err := r.db.First(&link, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// GetActiveByTokenHash finds a link that is neither revoked nor expired
+func (r *ShareLinkRepository) GetActiveByTokenHash(tokenHash string) (*models.NoteShareLink, error) {
+	var link models.NoteShareLink
+	err := r.db.
+		Where("token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", tokenHash, time.Now()).
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Revoke disables a share link
+func (r *ShareLinkRepository) Revoke(id uuid.UUID) error {
+	return r.db.Model(&models.NoteShareLink{}).Where("id = ?", id).Update("revoked_at", time.Now()).Error
+}
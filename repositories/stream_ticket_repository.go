@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+	"todo-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrStreamTicketInvalid = errors.New("stream ticket is invalid, used or expired")
+
+type StreamTicketRepository struct {
+	db *gorm.DB
+}
+
+func NewStreamTicketRepository(db *gorm.DB) *StreamTicketRepository {
+	return &StreamTicketRepository{db: db}
+}
+
+// Create stores a new ticket, clearing out expired ones on the way
+func (r *StreamTicketRepository) Create(ticket *models.StreamTicket) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&models.StreamTicket{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(ticket).Error
+	})
+}
+
+// Redeem uses up the unexpired ticket with tokenHash and returns the user it was issued to
+func (r *StreamTicketRepository) Redeem(tokenHash string) (string, error) {
+	var ticket models.StreamTicket
+	result := r.db.Clauses(clause.Returning{}).
+		Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).
+		Delete(&ticket)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrStreamTicketInvalid
+	}
+	return ticket.UserID, nil
+}
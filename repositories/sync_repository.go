@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+	"todo-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// SyncChanges holds every note, checklist item and reminder a user can access that changed after a point in time,
+// including all of those on notes shared with them since. Deleted rows are included with DeletedAt set so clients
+// can drop them, as are the notes the user lost access to in Revoked.
+type SyncChanges struct {
+	Notes          []models.Note
+	ChecklistItems []models.ChecklistItem
+	Reminders      []models.Reminder
+	Revoked        []models.NoteRevocation
+}
+
+type SyncRepository struct {
+	db *gorm.DB
+}
+
+func NewSyncRepository(db *gorm.DB) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+// ChangesSince returns rows changed or deleted after since. A zero since returns a full snapshot of live rows.
+func (r *SyncRepository) ChangesSince(userID string, since time.Time) (*SyncChanges, error) {
+	var changes SyncChanges
+	user := sql.Named("user", userID)
+	accessibleNoteIDs := r.db.Unscoped().Model(&models.Note{}).Select("notes.id").Where(accessibleByUser, user)
+
+	notes := r.db.Unscoped().Where(accessibleByUser, user)
+	items := r.db.Unscoped().Where("note_id IN (?)", accessibleNoteIDs)
+	reminders := r.db.Unscoped().Where("note_id IN (?)", accessibleNoteIDs)
+	if since.IsZero() {
+		notes = notes.Where("notes.deleted_at IS NULL")
+		items = items.Where("deleted_at IS NULL")
+		reminders = reminders.Where("deleted_at IS NULL")
+	} else {
+		// A note shared since hasn't necessarily changed since, but is new to the user
+		sharedNoteIDs := r.db.Model(&models.NoteCollaborator{}).Select("note_id").Where("user_id = ? AND created_at > ?", userID, since)
+		notes = notes.Where("(notes.updated_at > ? OR notes.deleted_at > ? OR notes.id IN (?))", since, since, sharedNoteIDs)
+		items = items.Where("(updated_at > ? OR deleted_at > ? OR note_id IN (?))", since, since, sharedNoteIDs)
+		reminders = reminders.Where("(updated_at > ? OR deleted_at > ? OR note_id IN (?))", since, since, sharedNoteIDs)
+
+		if err := r.db.Where("user_id = ? AND revoked_at > ?", userID, since).Order("revoked_at").Find(&changes.Revoked).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := notes.Order("notes.updated_at").Find(&changes.Notes).Error; err != nil {
+		return nil, err
+	}
+	if err := items.Order("updated_at").Find(&changes.ChecklistItems).Error; err != nil {
+		return nil, err
+	}
+	if err := reminders.Order("updated_at").Find(&changes.Reminders).Error; err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"todo-backend/models"
+)
+
+var weekStartDays = map[string]time.Weekday{
+	"monday":   time.Monday,
+	"sunday":   time.Sunday,
+	"saturday": time.Saturday,
+}
+
+// ValidatePreferences checks the fields that binding tags can't: the time zone and the default reminder time
+func ValidatePreferences(prefs *models.UserPreferences) error {
+	if _, err := ReminderLocation(prefs.Timezone); err != nil {
+		return errors.New("unknown timezone " + prefs.Timezone)
+	}
+	if _, _, err := parseClock(prefs.DefaultReminderTime); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PreferredLocation is the user's time zone, UTC if unset or no longer known
+func PreferredLocation(prefs models.UserPreferences) *time.Location {
+	loc, err := ReminderLocation(prefs.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// PreferredReminderClock is the hour and minute reminders default to when given a day but no time
+func PreferredReminderClock(prefs models.UserPreferences) (hour, minute int) {
+	hour, minute, err := parseClock(prefs.DefaultReminderTime)
+	if err != nil {
+		hour, minute, _ = parseClock(models.DefaultUserPreferences().DefaultReminderTime)
+	}
+	return hour, minute
+}
+
+// PreferredWeekStart is the first day of the user's week
+func PreferredWeekStart(prefs models.UserPreferences) time.Weekday {
+	if wd, ok := weekStartDays[prefs.WeekStart]; ok {
+		return wd
+	}
+	return time.Monday
+}
+
+// parseClock reads a 24-hour "HH:MM" time of day
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("time must be HH:MM, got %q", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
@@ -1,12 +1,18 @@
 package repositories
 
 import (
+	"errors"
+	"time"
 	"todo-backend/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrLastAdmin means a change would leave no admin
+var ErrLastAdmin = errors.New("the last admin can't be demoted or deleted")
+
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -43,24 +49,162 @@ func (r *UserRepository) FindByClerkID(clerkID string) (*models.User, error) {
 	return &user, nil
 }
 
-// Get user by email
+// FindByNoteUserID resolves the identifier notes store in CreatedBy and collaborator entries,
+// which is the Clerk ID for Clerk users and the user's UUID otherwise. Returns nil if unknown.
+func (r *UserRepository) FindByNoteUserID(id string) (*models.User, error) {
+	user, err := r.FindByClerkID(id)
+	if user != nil || err != nil {
+		return user, err
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+	user, err = r.GetByID(userID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return user, err
+}
+
+// EnsureClerkUser loads the row for user.ClerkID into user, creating it from user's profile
+// fields if the Clerk user hasn't been stored yet
+func (r *UserRepository) EnsureClerkUser(user *models.User) error {
+	return r.db.Where("clerk_id = ?", user.ClerkID).FirstOrCreate(user).Error
+}
+
+// UpsertClerkUser creates or updates the row for user.ClerkID with user's profile fields and,
+// if user.Role is admin, promotes it, unless the row already holds a profile at least as new as user.ClerkUpdatedAt: Svix doesn't keep
+// events in order, so a late update must not overwrite a newer one. A user deleted in the
+// meantime stays deleted, so a late update can't bring it back.
+func (r *UserRepository) UpsertClerkUser(user *models.User) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "clerk_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "clerk_id <> ''"}}},
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{
+			SQL: "users.deleted_at IS NULL AND (users.clerk_updated_at IS NULL OR users.clerk_updated_at < excluded.clerk_updated_at)",
+		}}},
+		DoUpdates: append(
+			clause.AssignmentColumns([]string{"email", "email_verified", "first_name", "last_name", "image_url", "clerk_updated_at", "updated_at"}),
+			// Syncing may promote a user to admin but never demotes one
+			clause.Assignment{Column: clause.Column{Name: "role"}, Value: gorm.Expr("CASE WHEN excluded.role = ? THEN excluded.role ELSE users.role END", models.UserRoleAdmin)},
+		),
+	}).Create(user).Error
+}
+
+// DeleteByClerkID soft-deletes the row for a Clerk user. Without one, it stores a deleted row
+// for the Clerk ID instead, so a user.created that arrives late can't bring the user to life.
+func (r *UserRepository) DeleteByClerkID(clerkID string) error {
+	res := r.db.Where("clerk_id = ?", clerkID).Delete(&models.User{})
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.Error
+	}
+	tombstone := models.User{
+		ClerkID:     clerkID,
+		Role:        models.UserRoleUser,
+		Preferences: models.DefaultUserPreferences(),
+		DeletedAt:   gorm.DeletedAt{Time: time.Now(), Valid: true},
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "clerk_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "clerk_id <> ''"}}},
+		DoNothing:   true,
+	}).Create(&tombstone).Error
+}
+
+// GetPreferences returns the preferences of the user notes know by id, or the defaults for a
+// user without a row
+func (r *UserRepository) GetPreferences(id string) (models.UserPreferences, error) {
+	user, err := r.FindByNoteUserID(id)
+	if err != nil || user == nil {
+		return models.DefaultUserPreferences(), err
+	}
+	return user.Preferences, nil
+}
+
+// UpdatePreferences overwrites all of a user's preferences
+func (r *UserRepository) UpdatePreferences(user *models.User, prefs models.UserPreferences) error {
+	err := r.db.Model(user).Updates(map[string]interface{}{
+		"pref_timezone":              prefs.Timezone,
+		"pref_week_start":            prefs.WeekStart,
+		"pref_default_note_sort":     prefs.DefaultNoteSort,
+		"pref_default_reminder_time": prefs.DefaultReminderTime,
+		"pref_theme":                 prefs.Theme,
+	}).Error
+	if err != nil {
+		return err
+	}
+	user.Preferences = prefs
+	return nil
+}
+
+// Get user by email, ignoring case
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByVerifiedEmail finds the user who proved they own email, ignoring case
+func (r *UserRepository) GetByVerifiedEmail(email string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("LOWER(email) = LOWER(?) AND email_verified", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// Update a user
-func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+// EnsureClerkIDIndex drops the old unique index on clerk_id. Email and password accounts all
+// have an empty ClerkID, so under that index only the first of them could ever register.
+// AutoMigrate has already created its replacement, which skips empty IDs.
+func (r *UserRepository) EnsureClerkIDIndex() error {
+	return r.db.Exec("DROP INDEX IF EXISTS idx_users_clerk_id").Error
 }
 
-// Delete a user (soft delete) by UUID
+// UpdateProfile writes only the given columns of user's row, so it can't undo a concurrent
+// password reset or preferences change, then reloads user. Demoting the last admin fails with
+// ErrLastAdmin.
+func (r *UserRepository) UpdateProfile(user *models.User, updates map[string]interface{}) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if role, ok := updates["role"]; ok && role != models.UserRoleAdmin {
+			if err := keepAnAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(user).Error
+	})
+}
+
+// Delete a user (soft delete) by UUID. Deleting the last admin fails with ErrLastAdmin.
 func (r *UserRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.User{}, "id = ?", id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepAnAdmin(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, "id = ?", id).Error
+	})
+}
+
+// keepAnAdmin returns ErrLastAdmin if id is the only admin. It locks the admin rows so two
+// admins can't demote each other at the same time.
+func keepAnAdmin(tx *gorm.DB, id uuid.UUID) error {
+	var admins []models.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("role = ?", models.UserRoleAdmin).Find(&admins).Error
+	if err != nil {
+		return err
+	}
+	if len(admins) == 1 && admins[0].ID == id {
+		return ErrLastAdmin
+	}
+	return nil
 }
 
 // List all users
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"todo-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookEventRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) *WebhookEventRepository {
+	return &WebhookEventRepository{db: db}
+}
+
+// Process runs apply for an event unless it was already processed, recording it in the same
+// transaction so a failed apply can be retried. It reports whether apply ran.
+func (r *WebhookEventRepository) Process(event *models.WebhookEvent, apply func(tx *gorm.DB) error) (bool, error) {
+	applied := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		applied = true
+		return apply(tx)
+	})
+	return applied && err == nil, err
+}
@@ -0,0 +1,430 @@
+// Package rrule parses iCalendar (RFC 5545) recurrence rules and expands them into
+// occurrences. It supports the parts reminders need: FREQ (DAILY, WEEKLY, MONTHLY,
+// YEARLY), INTERVAL, COUNT, UNTIL, BYDAY (with ordinals such as 1MO or -1FR), BYMONTHDAY,
+// BYMONTH, BYSETPOS and WKST.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds expansion so a rule that can never match (e.g. BYMONTH=2;BYMONTHDAY=30) terminates
+const maxPeriods = 50000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally the Nth (or Nth from last when negative) in the month or year
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR". A leading "RRULE:" is allowed.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(value)
+		case "COUNT":
+			rule.Count, err = parsePositive(value)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			rule.Until = &until
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var day WeekdayNum
+				if day, err = parseWeekdayNum(v); err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(value, 1, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value, 1, 12)
+			for _, m := range months {
+				if m < 0 {
+					err = fmt.Errorf("BYMONTH must be positive")
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseInts(value, 1, 366)
+		case "WKST":
+			day, ok := weekdays[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", value)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL can't both be set", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalidRule)
+		}
+	}
+	return rule, nil
+}
+
+// Iterator yields a rule's occurrences in order
+type Iterator struct {
+	rule    *Rule
+	start   time.Time
+	period  int
+	pending []time.Time
+	emitted int
+	done    bool
+}
+
+// Iter expands the rule from dtstart, whose location and time of day every occurrence shares.
+// Occurrences before dtstart are not produced.
+func (r *Rule) Iter(dtstart time.Time) *Iterator {
+	return &Iterator{rule: r, start: dtstart}
+}
+
+// Next returns the next occurrence, or false once the rule is exhausted
+func (it *Iterator) Next() (time.Time, bool) {
+	for len(it.pending) == 0 {
+		if it.done || it.period >= maxPeriods {
+			return time.Time{}, false
+		}
+		for _, t := range it.rule.expand(it.start, it.period) {
+			if !t.Before(it.start) {
+				it.pending = append(it.pending, t)
+			}
+		}
+		it.period++
+	}
+
+	t := it.pending[0]
+	it.pending = it.pending[1:]
+	if it.rule.Until != nil && t.After(*it.rule.Until) {
+		it.done, it.pending = true, nil
+		return time.Time{}, false
+	}
+	it.emitted++
+	if it.rule.Count > 0 && it.emitted >= it.rule.Count {
+		it.done = true
+		it.pending = nil
+	}
+	return t, true
+}
+
+// expand lists the occurrences that fall in the n-th period (day, week, month or year) after dtstart's
+func (r *Rule) expand(start time.Time, n int) []time.Time {
+	loc := start.Location()
+	y, m, d := start.Date()
+	hh, mm, ss := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hh, mm, ss, 0, loc)
+	}
+	step := n * r.Interval
+
+	var candidates []time.Time
+	switch r.Freq {
+	case Daily:
+		t := at(y, m, d+step)
+		if r.matchesMonth(t) && r.matchesMonthDay(t) && r.matchesWeekday(t) {
+			candidates = append(candidates, t)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(y, m, d-offset+7*step)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []WeekdayNum{{Day: start.Weekday()}}
+		}
+		for _, day := range days {
+			t := weekStart.AddDate(0, 0, (int(day.Day)-int(r.WeekStart)+7)%7)
+			t = at(t.Year(), t.Month(), t.Day())
+			if r.matchesMonth(t) {
+				candidates = append(candidates, t)
+			}
+		}
+	case Monthly:
+		first := at(y, m+time.Month(step), 1)
+		if r.matchesMonth(first) {
+			candidates = r.expandMonth(first.Year(), first.Month(), start.Day(), at)
+		}
+	case Yearly:
+		year := y + step
+		months := r.ByMonth
+		if len(months) == 0 && len(r.ByDay) > 0 && len(r.ByMonthDay) == 0 {
+			candidates = r.expandYearByDay(year, at)
+			break
+		}
+		switch {
+		case len(months) == 0 && len(r.ByMonthDay) > 0:
+			// BYMONTHDAY alone picks those days of every month
+			months = []time.Month{time.January, time.February, time.March, time.April, time.May, time.June,
+				time.July, time.August, time.September, time.October, time.November, time.December}
+		case len(months) == 0:
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			candidates = append(candidates, r.expandMonth(year, month, start.Day(), at)...)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	return r.applySetPos(dedupe(candidates))
+}
+
+// expandMonth lists matching days of one month; without BYDAY/BYMONTHDAY it's dtstart's day of month
+func (r *Rule) expandMonth(year int, month time.Month, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	daysIn := at(year, month+1, 0).Day()
+	var days []int
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = daysIn + md + 1
+			}
+			if md >= 1 && md <= daysIn {
+				days = append(days, md)
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			days = append(days, weekdaysIn(year, month, 1, daysIn, wd, at)...)
+		}
+	default:
+		if defaultDay <= daysIn {
+			days = append(days, defaultDay)
+		}
+	}
+
+	var out []time.Time
+	for _, day := range days {
+		t := at(year, month, day)
+		// BYDAY narrows BYMONTHDAY when both are given
+		if len(r.ByMonthDay) > 0 && len(r.ByDay) > 0 && !r.matchesWeekdayInMonth(t, daysIn, at) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// expandYearByDay handles YEARLY BYDAY without BYMONTH, where ordinals count through the whole year
+func (r *Rule) expandYearByDay(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	daysIn := at(year+1, time.January, 0).YearDay()
+	var out []time.Time
+	for _, wd := range r.ByDay {
+		for _, day := range weekdaysIn(year, time.January, 1, daysIn, wd, at) {
+			out = append(out, at(year, time.January, day))
+		}
+	}
+	return out
+}
+
+// weekdaysIn returns the days from..to (day numbers relative to month, may run past its end)
+// falling on wd.Day, or only the wd.N-th of them when N is set
+func weekdaysIn(year int, month time.Month, from, to int, wd WeekdayNum, at func(int, time.Month, int) time.Time) []int {
+	var matches []int
+	for day := from; day <= to; day++ {
+		if at(year, month, day).Weekday() == wd.Day {
+			matches = append(matches, day)
+		}
+	}
+	if wd.N == 0 {
+		return matches
+	}
+	i := wd.N - 1
+	if wd.N < 0 {
+		i = len(matches) + wd.N
+	}
+	if i < 0 || i >= len(matches) {
+		return nil
+	}
+	return []int{matches[i]}
+}
+
+func (r *Rule) matchesWeekdayInMonth(t time.Time, daysIn int, at func(int, time.Month, int) time.Time) bool {
+	for _, wd := range r.ByDay {
+		for _, day := range weekdaysIn(t.Year(), t.Month(), 1, daysIn, wd, at) {
+			if day == t.Day() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if t.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysIn := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, md := range r.ByMonthDay {
+		if md == t.Day() || daysIn+md+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// applySetPos keeps only the BYSETPOS-th occurrences of a period
+func (r *Rule) applySetPos(candidates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return candidates
+	}
+	var out []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(candidates) + pos
+		}
+		if i >= 0 && i < len(candidates) {
+			out = append(out, candidates[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupe(out)
+}
+
+func dedupe(sorted []time.Time) []time.Time {
+	out := sorted[:0]
+	for i, t := range sorted {
+		if i == 0 || !t.Equal(sorted[i-1]) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func parsePositive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q must be a positive integer", s)
+	}
+	return n, nil
+}
+
+// parseInts reads a comma-separated list of non-zero integers within ±max
+func parseInts(s string, min, max int) ([]int, error) {
+	var out []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < -max || n > max || (n > 0 && n < min) {
+			return nil, fmt.Errorf("invalid value %q", v)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	wd := WeekdayNum{Day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// parseUntil accepts the UTC date-time form (20250131T090000Z) or a plain date (20250131, end of day UTC)
+func parseUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", s)
+}
@@ -0,0 +1,168 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// occurrences expands rule from dtstart, stopping after limit occurrences
+func occurrences(t *testing.T, rule string, dtstart time.Time, limit int) []time.Time {
+	t.Helper()
+	r, err := Parse(rule)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rule, err)
+	}
+	var out []time.Time
+	it := r.Iter(dtstart)
+	for len(out) < limit {
+		next, ok := it.Next()
+		if !ok {
+			break
+		}
+		out = append(out, next)
+	}
+	return out
+}
+
+func TestExpand(t *testing.T) {
+	utc := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		limit   int
+		want    []time.Time
+	}{
+		{
+			name: "daily", rule: "FREQ=DAILY", dtstart: utc(2025, 1, 30), limit: 3,
+			want: []time.Time{utc(2025, 1, 30), utc(2025, 1, 31), utc(2025, 2, 1)},
+		},
+		{
+			name: "COUNT stops the rule", rule: "FREQ=DAILY;INTERVAL=2;COUNT=3", dtstart: utc(2025, 1, 1), limit: 10,
+			want: []time.Time{utc(2025, 1, 1), utc(2025, 1, 3), utc(2025, 1, 5)},
+		},
+		{
+			name: "UNTIL as a date includes that whole day", rule: "FREQ=WEEKLY;UNTIL=20250115", dtstart: utc(2025, 1, 1), limit: 10,
+			want: []time.Time{utc(2025, 1, 1), utc(2025, 1, 8), utc(2025, 1, 15)},
+		},
+		{
+			name: "UNTIL as a date-time is inclusive", rule: "RRULE:FREQ=DAILY;UNTIL=20250103T090000Z", dtstart: utc(2025, 1, 1), limit: 10,
+			want: []time.Time{utc(2025, 1, 1), utc(2025, 1, 2), utc(2025, 1, 3)},
+		},
+		{
+			name: "weekly on several days", rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", dtstart: utc(2025, 1, 1), limit: 4,
+			want: []time.Time{utc(2025, 1, 3), utc(2025, 1, 13), utc(2025, 1, 17), utc(2025, 1, 27)},
+		},
+		{
+			name: "weekdays", rule: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", dtstart: utc(2025, 1, 2), limit: 4,
+			want: []time.Time{utc(2025, 1, 2), utc(2025, 1, 3), utc(2025, 1, 6), utc(2025, 1, 7)},
+		},
+		{
+			name: "second tuesday", rule: "FREQ=MONTHLY;BYDAY=2TU", dtstart: utc(2025, 1, 1), limit: 3,
+			want: []time.Time{utc(2025, 1, 14), utc(2025, 2, 11), utc(2025, 3, 11)},
+		},
+		{
+			name: "last friday", rule: "FREQ=MONTHLY;BYDAY=-1FR", dtstart: utc(2025, 1, 1), limit: 3,
+			want: []time.Time{utc(2025, 1, 31), utc(2025, 2, 28), utc(2025, 3, 28)},
+		},
+		{
+			name: "last day of the month", rule: "FREQ=MONTHLY;BYMONTHDAY=-1", dtstart: utc(2024, 1, 15), limit: 4,
+			want: []time.Time{utc(2024, 1, 31), utc(2024, 2, 29), utc(2024, 3, 31), utc(2024, 4, 30)},
+		},
+		{
+			name: "months without a 31st are skipped", rule: "FREQ=MONTHLY", dtstart: utc(2025, 1, 31), limit: 4,
+			want: []time.Time{utc(2025, 1, 31), utc(2025, 3, 31), utc(2025, 5, 31), utc(2025, 7, 31)},
+		},
+		{
+			name: "BYMONTHDAY=31 skips short months", rule: "FREQ=MONTHLY;BYMONTHDAY=31", dtstart: utc(2025, 4, 1), limit: 2,
+			want: []time.Time{utc(2025, 5, 31), utc(2025, 7, 31)},
+		},
+		{
+			name: "last weekday of the month", rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", dtstart: utc(2025, 1, 1), limit: 3,
+			want: []time.Time{utc(2025, 1, 31), utc(2025, 2, 28), utc(2025, 3, 31)},
+		},
+		{
+			name: "first and last weekend day", rule: "FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=1,-1", dtstart: utc(2025, 3, 1), limit: 4,
+			want: []time.Time{utc(2025, 3, 1), utc(2025, 3, 30), utc(2025, 4, 5), utc(2025, 4, 27)},
+		},
+		{
+			name: "friday the 13th", rule: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", dtstart: utc(2025, 1, 1), limit: 2,
+			want: []time.Time{utc(2025, 6, 13), utc(2026, 2, 13)},
+		},
+		{
+			name: "yearly on february 29th", rule: "FREQ=YEARLY", dtstart: utc(2024, 2, 29), limit: 2,
+			want: []time.Time{utc(2024, 2, 29), utc(2028, 2, 29)},
+		},
+		{
+			name: "thanksgiving", rule: "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", dtstart: utc(2025, 1, 1), limit: 2,
+			want: []time.Time{utc(2025, 11, 27), utc(2026, 11, 26)},
+		},
+		{
+			name: "yearly BYMONTHDAY without BYMONTH covers every month", rule: "FREQ=YEARLY;BYMONTHDAY=15", dtstart: utc(2025, 11, 1), limit: 3,
+			want: []time.Time{utc(2025, 11, 15), utc(2025, 12, 15), utc(2026, 1, 15)},
+		},
+		{
+			name: "impossible rule terminates", rule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", dtstart: utc(2025, 1, 1), limit: 1,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrences(t, tt.rule, tt.dtstart, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpandKeepsLocalTimeAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	// Summer time starts on 30 March 2025
+	got := occurrences(t, "FREQ=DAILY", time.Date(2025, 3, 29, 9, 0, 0, 0, berlin), 3)
+	wantUTC := []int{8, 7, 7}
+	for i, occ := range got {
+		if h, m, _ := occ.Clock(); h != 9 || m != 0 {
+			t.Errorf("occurrence %d at %v, want 09:00 local", i, occ)
+		}
+		if occ.UTC().Hour() != wantUTC[i] {
+			t.Errorf("occurrence %d at %v UTC, want %02d:00", i, occ.UTC(), wantUTC[i])
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d occurrences, want 3", len(got))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYSETPOS=0",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", rule, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package svix
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// The example message from Svix's documentation
+const (
+	testSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	testID        = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	testTimestamp = "1614265330"
+	testBody      = `{"test": 2432232314}`
+	testSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+var testTime = time.Unix(1614265330, 0)
+
+func testHeader(signature string) http.Header {
+	h := http.Header{}
+	h.Set("svix-id", testID)
+	h.Set("svix-timestamp", testTimestamp)
+	h.Set("svix-signature", signature)
+	return h
+}
+
+func TestVerify(t *testing.T) {
+	v, err := NewVerifier(testSecret)
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		now    time.Time
+		want   error
+	}{
+		{"valid signature", testHeader(testSignature), testBody, testTime, nil},
+		{"within the tolerance", testHeader(testSignature), testBody, testTime.Add(Tolerance), nil},
+		{"tampered body", testHeader(testSignature), `{"test": 2432232315}`, testTime, ErrInvalidSignature},
+		{"stale timestamp", testHeader(testSignature), testBody, testTime.Add(Tolerance + time.Second), ErrInvalidTimestamp},
+		{"timestamp in the future", testHeader(testSignature), testBody, testTime.Add(-Tolerance - time.Second), ErrInvalidTimestamp},
+		{"one of several signatures matches", testHeader("v1,Ceo5qEr07ixe2NLpvHk3FH9bwy/WavXrAFQ/9tdO6mc= " + testSignature), testBody, testTime, nil},
+		{"none of several signatures match", testHeader("v1,Ceo5qEr07ixe2NLpvHk3FH9bwy/WavXrAFQ/9tdO6mc= v1,bm90IGEgc2lnbmF0dXJl"), testBody, testTime, ErrInvalidSignature},
+		{"other signature versions are ignored", testHeader("v2" + testSignature[2:]), testBody, testTime, ErrInvalidSignature},
+		{"missing headers", http.Header{}, testBody, testTime, ErrMissingHeaders},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(tt.header, []byte(tt.body), tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithAnotherSecret(t *testing.T) {
+	v, err := NewVerifier("whsec_" + "c2VjcmV0LXRoYXQtaXMtbm90LXRoZS1vbmU=")
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+	if err := v.Verify(testHeader(testSignature), []byte(testBody), testTime); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewVerifierRejectsBadSecrets(t *testing.T) {
+	for _, secret := range []string{"", "whsec_", "whsec_not base64!"} {
+		if _, err := NewVerifier(secret); err == nil {
+			t.Errorf("NewVerifier(%q) succeeded", secret)
+		}
+	}
+}